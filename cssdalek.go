@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -9,15 +10,33 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/daaku/cssdalek/internal/archive"
+	"github.com/daaku/cssdalek/internal/cmdusage"
 	"github.com/daaku/cssdalek/internal/csspurge"
 	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/excludeusage"
+	"github.com/daaku/cssdalek/internal/extractor"
+	"github.com/daaku/cssdalek/internal/gomponentsusage"
+	"github.com/daaku/cssdalek/internal/gotmplusage"
+	"github.com/daaku/cssdalek/internal/htmlpurge"
 	"github.com/daaku/cssdalek/internal/htmlusage"
 	"github.com/daaku/cssdalek/internal/includeusage"
+	"github.com/daaku/cssdalek/internal/jsusage"
+	"github.com/daaku/cssdalek/internal/jsxusage"
+	"github.com/daaku/cssdalek/internal/mdusage"
+	"github.com/daaku/cssdalek/internal/statsusage"
+	"github.com/daaku/cssdalek/internal/svelteusage"
+	"github.com/daaku/cssdalek/internal/templusage"
+	"github.com/daaku/cssdalek/internal/tmplusage"
 	"github.com/daaku/cssdalek/internal/usage"
+	"github.com/daaku/cssdalek/internal/vueusage"
 	"github.com/daaku/cssdalek/internal/wordusage"
 
 	"github.com/facebookgo/errgroup"
@@ -44,85 +63,271 @@ func buildRe(ss []string) ([]*regexp.Regexp, error) {
 }
 
 type app struct {
-	CSSGlobs        []string `opts:"name=css,short=c,help=globs targeting CSS files"`
-	HTMLGlobs       []string `opts:"name=html,short=h,help=globs targeting HTML files"`
-	WordGlobs       []string `opts:"name=word,short=w,help=globs targeting word files"`
-	IncludeClass    []string `opts:"help=class regexp to include"`
-	IncludeID       []string `opts:"help=id regexp to include"`
-	IncludeSelector []string `opts:"short=i,help=selectors to include"`
-	Verbose         bool     `opts:"short=v,help=verbose logging"`
-	Version         bool     `opts:"short=V,help=version & build information"`
+	CSSGlobs         []string `opts:"name=css,short=c,help=globs targeting CSS files"`
+	HTMLGlobs        []string `opts:"name=html,short=h,help=globs targeting HTML files"`
+	GoTmplGlobs      []string `opts:"name=gotmpl,help=globs targeting Go html/template files"`
+	JSXGlobs         []string `opts:"name=jsx,help=globs targeting JSX and TSX files"`
+	JSXHelpers       []string `opts:"name=jsx-helper,help=functions whose arguments are classes in addition to clsx cn classnames classNames & cx"`
+	VueGlobs         []string `opts:"name=vue,help=globs targeting Vue single-file components"`
+	PurgeVueGlobs    []string `opts:"name=purge-vue,help=globs targeting Vue single-file components to purge <style> blocks in place"`
+	SvelteGlobs      []string `opts:"name=svelte,help=globs targeting Svelte components"`
+	PurgeSvelteGlobs []string `opts:"name=purge-svelte,help=globs targeting Svelte components to purge <style> blocks in place"`
+	TemplGlobs       []string `opts:"name=templ,help=globs targeting templ files"`
+	GomponentsGlobs  []string `opts:"name=gomponents,help=globs targeting Go files using gomponents"`
+	TemplateGlobs    []string `opts:"name=template,help=globs targeting templates using delimited tags like Jinja Django Liquid & Handlebars"`
+	TemplateDelims   []string `opts:"name=template-delim,help=template tag delimiters like '{% %}' replacing the defaults"`
+	TemplateComments []string `opts:"name=template-comment,help=template comment delimiters like '{# #}' replacing the defaults"`
+	MarkdownGlobs    []string `opts:"name=md,help=globs targeting Markdown files"`
+	StatsGlobs       []string `opts:"name=stats,help=globs targeting hugo_stats.json files or other lists of tags classes & ids"`
+	InputGlobs       []string `opts:"name=input,help=globs targeting files of any supported type with the extractor picked by extension"`
+	Extractors       []string `opts:"name=extractor,help=extractors to use by extension like '.tpl=html'"`
+	Externals        []string `opts:"name=external,help=external extractor commands like 'name=command args' used by extension with --extractor"`
+	WordGlobs        []string `opts:"name=word,short=w,help=globs targeting word files"`
+	JSGlobs          []string `opts:"name=js,help=globs targeting JavaScript and TypeScript files whose string literals are words"`
+	JSComments       bool     `opts:"help=also use the words in comments of JavaScript and TypeScript files"`
+	WordPerFile      bool     `opts:"help=require all parts of a selector to be found in the same word file"`
+	WordTokenizer    string   `opts:"help=how word files are split into words: default bem or tailwind"`
+	WordRunes        string   `opts:"help=runes which are part of words in addition to letters & numbers replacing those of the tokenizer"`
+	WordSeparators   string   `opts:"help=runes at which words are also split into pieces replacing those of the tokenizer"`
+	PurgeHTMLGlobs   []string `opts:"name=purge-html,help=globs targeting HTML files to purge <style> elements in place"`
+	PurgeHTMLAll     bool     `opts:"help=purge <style> elements using usage from all inputs instead of their own document"`
+	Linked           bool     `opts:"help=purge stylesheets linked from HTML files against the files linking them"`
+	WebRoot          string   `opts:"help=directory absolute stylesheet links are relative to"`
+	IncludeClass     []string `opts:"help=class regexp to include"`
+	IncludeID        []string `opts:"help=id regexp to include"`
+	IncludeTag       []string `opts:"help=tag regexp to include"`
+	IncludeAttr      []string `opts:"help=attribute name regexp to include"`
+	IncludeKeyframes []string `opts:"name=include-keyframes,help=keyframes name regexp to keep regardless of usage"`
+	IncludeFontFace  []string `opts:"help=font face family regexp to keep regardless of usage"`
+	IncludeSelector  []string `opts:"short=i,help=selectors to include"`
+	IncludeGreedy    bool     `opts:"help=include selectors if any of their parts match the includes instead of all of them"`
+	IncludeDeep      bool     `opts:"help=keep every rule nested within a rule kept by the includes"`
+	IncludeAtRule    []string `opts:"help=at-rule regexp matching its name and prelude like @layer vendor whose nested rules are all kept regardless of usage"`
+	ExcludeClass     []string `opts:"help=class regexp to exclude regardless of usage"`
+	ExcludeID        []string `opts:"help=id regexp to exclude regardless of usage"`
+	ExcludeSelector  []string `opts:"help=selectors to exclude regardless of usage unless included by --include-selector"`
+	Verbose          bool     `opts:"short=v,help=verbose logging"`
+	Version          bool     `opts:"short=V,help=version & build information"`
 
 	htmlInfoMu sync.Mutex
 	htmlInfo   htmlusage.Info
+	linkedInfo map[string]*htmlusage.Info
 
-	wordInfoMu sync.Mutex
-	wordInfo   wordusage.Info
+	wordInfoMu  sync.Mutex
+	wordInfo    wordusage.Info
+	wordDocInfo wordusage.DocInfo
+
+	statsInfoMu sync.Mutex
+	statsInfo   *statsusage.Info
 
 	cssInfoMu sync.Mutex
 	cssInfo   cssusage.Info
+	keepCSS   cssusage.Info
+
+	otherInfoMu sync.Mutex
+	otherInfo   usage.MultiInfo
+
+	exclude        excludeusage.MultiExcluder
+	keep           usage.Info
+	templateDelims []tmplusage.Delim
+	wordTokenizer  wordusage.Tokenizer
+	extractors     extractor.Registry
+	entrySem       chan struct{} // limits the archive entries held in memory
 
 	log *log.Logger
 }
 
-func (a *app) build(eg *errgroup.Group, globs []string, b func(r io.Reader) error) {
+// build processes each file matching the globs concurrently. The entries
+// within archives are read into memory first, with at most one per CPU held
+// at a time.
+func (a *app) build(eg *errgroup.Group, globs []string, b func(filename string, r io.Reader) error) {
 	defer eg.Done()
 	eg.Add(len(globs))
 	for _, glob := range globs {
 		glob := glob
 		go func() {
 			defer eg.Done()
-			matches, err := filepath.Glob(glob)
-			if err != nil {
-				eg.Error(errors.WithStack(err))
-				return
-			}
-			eg.Add(len(matches))
-			for _, filename := range matches {
-				filename := filename
+			err := archive.Walk(glob, func(file *archive.File) error {
+				if file.InArchive() {
+					a.entrySem <- struct{}{}
+					if err := file.Buffer(); err != nil {
+						<-a.entrySem
+						return err
+					}
+				}
+				eg.Add(1)
 				go func() {
 					defer eg.Done()
-					a.log.Printf("Processing file: %s\n", filename)
-					f, err := os.Open(filename)
-					if err != nil {
-						eg.Error(errors.WithStack(err))
-						return
-					}
-					defer f.Close()
-					if err := b(bufio.NewReader(f)); err != nil {
-						eg.Error(errors.WithMessagef(err, "in file: %q", filename))
-						return
+					if file.InArchive() {
+						defer func() { <-a.entrySem }()
 					}
+					a.buildFile(eg, file, b)
 				}()
+				return nil
+			})
+			if err != nil {
+				eg.Error(err)
 			}
 		}()
 	}
-
 }
 
-func (a *app) buildHTMLInfo(r io.Reader) error {
-	info, err := htmlusage.Extract(r)
+func (a *app) buildFile(eg *errgroup.Group, file *archive.File, b func(filename string, r io.Reader) error) {
+	a.log.Printf("Processing file: %s\n", file.Name)
+	f, err := file.Open()
 	if err != nil {
-		return err
+		eg.Error(err)
+		return
+	}
+	defer f.Close()
+	if err := b(file.Name, bufio.NewReader(f)); err != nil {
+		eg.Error(errors.WithMessagef(err, "in file: %q", file.Name))
+	}
+}
+
+// htmlExtractor adapts an extract function returning HTML usage information.
+func htmlExtractor(extract func(r io.Reader) (*htmlusage.Info, error)) extractor.Func {
+	return func(filename string, r io.Reader) (usage.Info, error) {
+		return extract(r)
 	}
-	a.htmlInfoMu.Lock()
-	a.htmlInfo.Merge(info)
-	a.htmlInfoMu.Unlock()
-	return nil
 }
 
-func (a *app) buildWordInfo(r io.Reader) error {
-	info, err := wordusage.Extract(r)
+// registerExtractors registers the built in extractors, followed by those
+// registered with the extractor package, which may replace them.
+func (a *app) registerExtractors() {
+	r := &a.extractors
+	r.Register("html", htmlExtractor(htmlusage.Extract), ".html", ".htm", ".xhtml")
+	r.Register("gotmpl", htmlExtractor(gotmplusage.Extract), ".gohtml", ".tmpl")
+	helpers := append(append([]string{}, jsxusage.DefaultHelpers...), a.JSXHelpers...)
+	r.Register("jsx", htmlExtractor(func(r io.Reader) (*htmlusage.Info, error) {
+		return jsxusage.Extract(r, helpers)
+	}), ".jsx", ".tsx", ".js", ".mjs", ".ts")
+	r.Register("vue", htmlExtractor(vueusage.Extract), ".vue")
+	r.Register("svelte", htmlExtractor(svelteusage.Extract), ".svelte")
+	r.Register("templ", htmlExtractor(templusage.Extract), ".templ")
+	r.Register("gomponents", htmlExtractor(gomponentsusage.Extract), ".go")
+	r.Register("template", htmlExtractor(func(r io.Reader) (*htmlusage.Info, error) {
+		return tmplusage.Extract(r, a.templateDelims)
+	}), ".j2", ".jinja", ".jinja2", ".njk", ".liquid", ".hbs", ".handlebars", ".mustache", ".twig")
+	r.Register("md", htmlExtractor(mdusage.Extract), ".md", ".markdown")
+	r.Register("word", extractor.Func(func(filename string, r io.Reader) (usage.Info, error) {
+		return a.wordTokenizer.Extract(r)
+	}), ".txt")
+	r.Register("js", extractor.Func(func(filename string, r io.Reader) (usage.Info, error) {
+		return jsusage.Extract(r, &a.wordTokenizer, a.JSComments)
+	}))
+	r.Register("stats", extractor.Func(func(filename string, r io.Reader) (usage.Info, error) {
+		return statsusage.Extract(r)
+	}))
+	r.Include(extractor.Default)
+}
+
+// registerExternal registers an external command as an extractor, given like
+// "name=command args".
+func (a *app) registerExternal(external string) error {
+	i := strings.IndexByte(external, '=')
+	if i < 1 {
+		return errors.Errorf("invalid external extractor %q, expected one like name=command", external)
+	}
+	c, err := cmdusage.Parse(external[i+1:])
 	if err != nil {
 		return err
 	}
-	a.wordInfoMu.Lock()
-	a.wordInfo.Merge(info)
-	a.wordInfoMu.Unlock()
+	a.extractors.Register(strings.TrimSpace(external[:i]), extractor.Func(func(filename string, r io.Reader) (usage.Info, error) {
+		html, words, err := c.Extract(filename, r)
+		if err != nil {
+			return nil, err
+		}
+		return usage.MultiInfo{html, words}, nil
+	}))
 	return nil
 }
 
-func (a *app) buildCSSInfo(r io.Reader) error {
+// extract returns a build function using the named extractor, or the one for
+// the extension of each file if the name is empty.
+func (a *app) extract(name string) func(filename string, r io.Reader) error {
+	return func(filename string, r io.Reader) error {
+		var e extractor.Extractor
+		var err error
+		if name == "" {
+			e, err = a.extractors.ForFile(filename)
+		} else {
+			e, err = a.extractors.Get(name)
+		}
+		if err != nil {
+			return err
+		}
+		info, err := e.Extract(filename, r)
+		if err != nil {
+			return err
+		}
+		a.mergeInfo(filename, info)
+		return nil
+	}
+}
+
+// mergeInfo merges the usage information from an extractor. HTML and word
+// usage information is merged with that from other files, while any other
+// kind is kept as it is.
+func (a *app) mergeInfo(filename string, info usage.Info) {
+	switch info := info.(type) {
+	case usage.MultiInfo:
+		for _, i := range info {
+			a.mergeInfo(filename, i)
+		}
+	case *htmlusage.Info:
+		a.mergeHTMLInfo(filename, info)
+	case *wordusage.Info:
+		for _, p := range info.SortedPatterns() {
+			a.log.Printf("Inferred class pattern %q in file: %s\n", p, filename)
+		}
+		a.wordInfoMu.Lock()
+		if a.WordPerFile {
+			a.wordDocInfo.Merge(info)
+		} else {
+			a.wordInfo.Merge(info)
+		}
+		a.wordInfoMu.Unlock()
+	case *statsusage.Info:
+		a.statsInfoMu.Lock()
+		if a.statsInfo == nil {
+			a.statsInfo = new(statsusage.Info)
+		}
+		a.statsInfo.Merge(info)
+		a.statsInfoMu.Unlock()
+	default:
+		a.otherInfoMu.Lock()
+		a.otherInfo = append(a.otherInfo, info)
+		a.otherInfoMu.Unlock()
+	}
+}
+
+func (a *app) mergeHTMLInfo(filename string, info *htmlusage.Info) {
+	for _, p := range info.SortedPatterns() {
+		a.log.Printf("Inferred class pattern %q in file: %s\n", p, filename)
+	}
+	a.htmlInfoMu.Lock()
+	defer a.htmlInfoMu.Unlock()
+	a.htmlInfo.Merge(info)
+	if a.Linked {
+		for _, target := range info.Stylesheets {
+			stylesheet, ok := htmlusage.Resolve(a.WebRoot, filename, target)
+			if !ok {
+				a.log.Printf("Ignoring stylesheet %q in file: %s\n", target, filename)
+				continue
+			}
+			if a.linkedInfo == nil {
+				a.linkedInfo = make(map[string]*htmlusage.Info)
+			}
+			if a.linkedInfo[stylesheet] == nil {
+				a.linkedInfo[stylesheet] = new(htmlusage.Info)
+			}
+			a.linkedInfo[stylesheet].Merge(info)
+		}
+	}
+}
+
+func (a *app) buildCSSInfo(filename string, r io.Reader) error {
 	info, err := cssusage.Extract(r)
 	if err != nil {
 		return err
@@ -133,6 +338,115 @@ func (a *app) buildCSSInfo(r io.Reader) error {
 	return nil
 }
 
+// excluded returns the usage information dropping the excluded selectors, if
+// there are any.
+func (a *app) excluded(u usage.Info) usage.Info {
+	if len(a.exclude) == 0 {
+		return u
+	}
+	return &excludeusage.Info{Exclude: a.exclude, Keep: a.keep, Usage: u}
+}
+
+// purgeHTML rewrites the file, purging its <style> elements. Unless purging
+// using all inputs, the usage from the document itself is added to u.
+func (a *app) purgeHTML(filename string, u usage.Info) error {
+	a.log.Printf("Purging file: %s\n", filename)
+	doc, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	style := &a.htmlInfo.Style
+	if !a.PurgeHTMLAll {
+		info, err := htmlusage.Extract(bytes.NewReader(doc))
+		if err != nil {
+			return err
+		}
+		u = usage.MultiInfo{u, info}
+		style = &info.Style
+	}
+	u = a.excluded(u)
+	var cssInfo cssusage.Info
+	cssInfo.Merge(style)
+	cssInfo.Merge(&a.keepCSS)
+	var out bytes.Buffer
+	if err := htmlpurge.Purge(u, &cssInfo, a.log, bytes.NewReader(doc), &out); err != nil {
+		return err
+	}
+	return rewrite(filename, out.Bytes())
+}
+
+// purgeVue rewrites the component, purging its <style> blocks. Scoped styles
+// use the usage from the component itself along with u, while other styles
+// also use the global usage.
+func (a *app) purgeVue(filename string, u, global usage.Info) error {
+	a.log.Printf("Purging file: %s\n", filename)
+	doc, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var out bytes.Buffer
+	if err := vueusage.Purge(u, global, a.excluded, &a.keepCSS, a.log, bytes.NewReader(doc), &out); err != nil {
+		return err
+	}
+	return rewrite(filename, out.Bytes())
+}
+
+// purgeSvelte rewrites the component, purging its <style> blocks using the
+// usage from the component itself along with u.
+func (a *app) purgeSvelte(filename string, u usage.Info) error {
+	a.log.Printf("Purging file: %s\n", filename)
+	doc, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var out bytes.Buffer
+	if err := svelteusage.Purge(u, a.excluded, &a.keepCSS, a.log, bytes.NewReader(doc), &out); err != nil {
+		return err
+	}
+	return rewrite(filename, out.Bytes())
+}
+
+// rewrite replaces the contents of the file, keeping its mode.
+func rewrite(filename string, data []byte) error {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(filename, data, fi.Mode()))
+}
+
+// purgeLinked purges each stylesheet linked from the HTML files, using only
+// the usage from the files linking it.
+func (a *app) purgeLinked(includeInfo, wordInfo usage.Info, w io.Writer) error {
+	stylesheets := make([]string, 0, len(a.linkedInfo))
+	for stylesheet := range a.linkedInfo {
+		stylesheets = append(stylesheets, stylesheet)
+	}
+	sort.Strings(stylesheets)
+	for _, stylesheet := range stylesheets {
+		css, err := ioutil.ReadFile(stylesheet)
+		if os.IsNotExist(err) {
+			a.log.Printf("Ignoring missing stylesheet: %s\n", stylesheet)
+			continue
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		a.log.Printf("Purging linked stylesheet: %s\n", stylesheet)
+		cssInfo, err := cssusage.Extract(bytes.NewReader(css))
+		if err != nil {
+			return errors.WithMessagef(err, "in file %q", stylesheet)
+		}
+		cssInfo.Merge(&a.linkedInfo[stylesheet].Style)
+		cssInfo.Merge(&a.keepCSS)
+		u := a.excluded(usage.MultiInfo{includeInfo, a.linkedInfo[stylesheet], wordInfo})
+		if err := csspurge.Purge(u, cssInfo, a.log, bytes.NewReader(css), w); err != nil {
+			return errors.WithMessagef(err, "in file %q", stylesheet)
+		}
+	}
+	return nil
+}
+
 func (a *app) run() error {
 	if a.Version {
 		if bi, ok := debug.ReadBuildInfo(); ok {
@@ -164,52 +478,233 @@ func (a *app) run() error {
 		return err
 	}
 
+	includeTag, err := buildRe(a.IncludeTag)
+	if err != nil {
+		return err
+	}
+
+	includeAttr, err := buildRe(a.IncludeAttr)
+	if err != nil {
+		return err
+	}
+
+	if a.keepCSS.KeepKeyframesRe, err = buildRe(a.IncludeKeyframes); err != nil {
+		return err
+	}
+	if a.keepCSS.KeepFontFaceRe, err = buildRe(a.IncludeFontFace); err != nil {
+		return err
+	}
+	if a.keepCSS.KeepAtRuleRe, err = buildRe(a.IncludeAtRule); err != nil {
+		return err
+	}
+
 	includeSelector, err := htmlusage.FromSelectors(a.IncludeSelector)
 	if err != nil {
 		return err
 	}
+	a.keep = includeSelector
+
+	excludeClass, err := buildRe(a.ExcludeClass)
+	if err != nil {
+		return err
+	}
+	if len(excludeClass) > 0 {
+		a.exclude = append(a.exclude, &excludeusage.ExcludeClass{Re: excludeClass})
+	}
+
+	excludeID, err := buildRe(a.ExcludeID)
+	if err != nil {
+		return err
+	}
+	if len(excludeID) > 0 {
+		a.exclude = append(a.exclude, &excludeusage.ExcludeID{Re: excludeID})
+	}
 
+	if len(a.ExcludeSelector) > 0 {
+		excludeSelector, err := excludeusage.FromSelectors(a.ExcludeSelector)
+		if err != nil {
+			return err
+		}
+		a.exclude = append(a.exclude, excludeSelector)
+	}
+
+	for _, d := range a.TemplateDelims {
+		delim, err := tmplusage.ParseDelim(d, false)
+		if err != nil {
+			return err
+		}
+		a.templateDelims = append(a.templateDelims, delim)
+	}
+	for _, d := range a.TemplateComments {
+		delim, err := tmplusage.ParseDelim(d, true)
+		if err != nil {
+			return err
+		}
+		a.templateDelims = append(a.templateDelims, delim)
+	}
+
+	tokenizer, found := wordusage.Tokenizers[a.WordTokenizer]
+	if !found {
+		return errors.Errorf("unknown word tokenizer %q, expected one of: default, bem, tailwind", a.WordTokenizer)
+	}
+	a.wordTokenizer = *tokenizer
+	if a.WordRunes != "" {
+		a.wordTokenizer.Runes = a.WordRunes
+	}
+	if a.WordSeparators != "" {
+		a.wordTokenizer.Separators = a.WordSeparators
+	}
+
+	// files are purged in place, which isn't possible within archives
+	for _, globs := range [][]string{a.PurgeHTMLGlobs, a.PurgeVueGlobs, a.PurgeSvelteGlobs} {
+		for _, glob := range globs {
+			if _, _, ok := archive.Split(glob); ok {
+				return errors.Errorf("invalid glob %q, files within archives can't be purged in place", glob)
+			}
+		}
+	}
+
+	a.registerExtractors()
+	for _, e := range a.Externals {
+		if err := a.registerExternal(e); err != nil {
+			return err
+		}
+	}
+	for _, m := range a.Extractors {
+		if err := a.extractors.ParseMapping(m); err != nil {
+			return err
+		}
+	}
+
+	a.entrySem = make(chan struct{}, runtime.NumCPU())
 	var eg errgroup.Group
-	eg.Add(3)
-	go a.build(&eg, a.HTMLGlobs, a.buildHTMLInfo)
-	go a.build(&eg, a.WordGlobs, a.buildWordInfo)
+	eg.Add(17)
+	go a.build(&eg, a.HTMLGlobs, a.extract("html"))
+	go a.build(&eg, a.GoTmplGlobs, a.extract("gotmpl"))
+	go a.build(&eg, a.JSXGlobs, a.extract("jsx"))
+	go a.build(&eg, a.VueGlobs, a.extract("vue"))
+	go a.build(&eg, a.PurgeVueGlobs, a.extract("vue"))
+	go a.build(&eg, a.SvelteGlobs, a.extract("svelte"))
+	go a.build(&eg, a.PurgeSvelteGlobs, a.extract("svelte"))
+	go a.build(&eg, a.TemplGlobs, a.extract("templ"))
+	go a.build(&eg, a.GomponentsGlobs, a.extract("gomponents"))
+	go a.build(&eg, a.TemplateGlobs, a.extract("template"))
+	go a.build(&eg, a.MarkdownGlobs, a.extract("md"))
+	go a.build(&eg, a.StatsGlobs, a.extract("stats"))
+	go a.build(&eg, a.InputGlobs, a.extract(""))
+	if a.PurgeHTMLAll {
+		go a.build(&eg, a.PurgeHTMLGlobs, a.extract("html"))
+	} else {
+		eg.Done()
+	}
+	go a.build(&eg, a.WordGlobs, a.extract("word"))
+	go a.build(&eg, a.JSGlobs, a.extract("js"))
 	go a.build(&eg, a.CSSGlobs, a.buildCSSInfo)
 	if err := eg.Wait(); err != nil {
 		return err
 	}
 
-	usageInfo := usage.MultiInfo{
-		includePreset,
+	var included usage.Info = usage.MultiInfo{
 		&includeusage.IncludeClass{Re: includeClass},
 		&includeusage.IncludeID{Re: includeID},
+		&includeusage.IncludeTag{Re: includeTag},
+		&includeusage.IncludeAttr{Re: includeAttr},
 		includeSelector,
+	}
+	if a.IncludeGreedy {
+		included = &includeusage.Greedy{Info: included}
+	}
+	if a.IncludeDeep {
+		a.keepCSS.Deep = included
+	}
+	includeInfo := usage.MultiInfo{includePreset, included}
+	usageInfo := usage.MultiInfo{
+		includeInfo,
 		&a.htmlInfo,
-		&a.wordInfo,
 	}
+	var wordInfo usage.Info = &a.wordInfo
+	if a.WordPerFile {
+		wordInfo = &a.wordDocInfo
+	}
+	usageInfo = append(usageInfo, wordInfo)
+	if a.statsInfo != nil {
+		usageInfo = append(usageInfo, a.statsInfo)
+	}
+	if len(a.otherInfo) > 0 {
+		usageInfo = append(usageInfo, a.otherInfo)
+	}
+
+	// font faces and keyframes used in style attributes are always kept
+	a.cssInfo.Merge(&a.htmlInfo.Style)
+	a.cssInfo.Merge(&a.keepCSS)
 
 	w := bufio.NewWriter(os.Stdout)
+	if a.Linked {
+		if err := a.purgeLinked(includeInfo, wordInfo, w); err != nil {
+			return err
+		}
+	}
 	for _, glob := range a.CSSGlobs {
+		err := archive.Walk(glob, func(file *archive.File) error {
+			f, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			err = csspurge.Purge(a.excluded(usageInfo), &a.cssInfo, a.log, bufio.NewReader(f), w)
+			return errors.WithMessagef(err, "in file %q", file.Name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	purgeHTMLInfo := usage.Info(includeInfo)
+	if a.PurgeHTMLAll {
+		purgeHTMLInfo = usageInfo
+	}
+	for _, glob := range a.PurgeHTMLGlobs {
 		matches, err := filepath.Glob(glob)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		for _, filename := range matches {
-			f, err := os.Open(filename)
-			if err != nil {
-				return errors.WithStack(err)
+			if err := a.purgeHTML(filename, purgeHTMLInfo); err != nil {
+				return errors.WithMessagef(err, "in file %q", filename)
 			}
-			err = csspurge.Purge(usageInfo, &a.cssInfo, a.log, bufio.NewReader(f), w)
-			if err != nil {
+		}
+	}
+
+	for _, glob := range a.PurgeVueGlobs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, filename := range matches {
+			if err := a.purgeVue(filename, includeInfo, usageInfo); err != nil {
 				return errors.WithMessagef(err, "in file %q", filename)
 			}
 		}
 	}
+
+	for _, glob := range a.PurgeSvelteGlobs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, filename := range matches {
+			if err := a.purgeSvelte(filename, includeInfo); err != nil {
+				return errors.WithMessagef(err, "in file %q", filename)
+			}
+		}
+	}
+
 	a.log.Println("Took", time.Since(start))
 	return errors.WithStack(w.Flush())
 }
 
 func main() {
-	var a app
+	a := app{WebRoot: ".", WordTokenizer: "default"}
 	opts.Parse(&a)
 	if err := a.run(); err != nil {
 		fmt.Fprintf(os.Stderr, "%+v\n", err)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+	"github.com/facebookgo/errgroup"
+)
+
+func TestBuildArchive(t *testing.T) {
+	const entries = 20
+	filename := filepath.Join(t.TempDir(), "site.tar.gz")
+	f, err := os.Create(filename)
+	ensure.Nil(t, err)
+	z := gzip.NewWriter(f)
+	w := tar.NewWriter(z)
+	for n := 0; n < entries; n++ {
+		page := fmt.Sprintf(`<p class="page%d"></p>`, n)
+		ensure.Nil(t, w.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("site/%d.html", n),
+			Mode:     0644,
+			Size:     int64(len(page)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := w.Write([]byte(page))
+		ensure.Nil(t, err)
+	}
+	ensure.Nil(t, w.Close())
+	ensure.Nil(t, z.Close())
+	ensure.Nil(t, f.Close())
+
+	a := app{log: log.New(ioutil.Discard, "", 0), entrySem: make(chan struct{}, 2)}
+	a.registerExtractors()
+	var eg errgroup.Group
+	eg.Add(1)
+	go a.build(&eg, []string{filename + "//**/*.html"}, a.extract("html"))
+	ensure.Nil(t, eg.Wait())
+
+	ensure.DeepEqual(t, len(a.htmlInfo.Docs), entries)
+	for n := 0; n < entries; n++ {
+		chain, err := cssselector.Parse(strings.NewReader(fmt.Sprintf("p.page%d", n)))
+		ensure.Nil(t, err)
+		ensure.True(t, a.htmlInfo.Includes(chain), "entry", n)
+	}
+}
@@ -0,0 +1,262 @@
+// Package archive finds the files matching globs, which may be within tar and
+// zip archives. A glob like "site.tar.gz//**/*.html" matches the entries within
+// the archives matching the part before the double slash, where ** matches any
+// number of directories. Files compressed with gzip are decompressed
+// transparently.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Separator separates the glob for archives from the glob for their entries.
+const Separator = "//"
+
+var extensions = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// isArchive returns true if the name has the extension of an archive.
+func isArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGzip returns true if the name has the extension of a gzip compressed file.
+func isGzip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".gz")
+}
+
+// Split returns the glob for the archives and the glob for their entries, if
+// the glob targets entries within archives.
+func Split(glob string) (archives, entries string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(glob[i:], Separator)
+		if j == -1 {
+			return "", "", false
+		}
+		j += i
+		if isArchive(glob[:j]) {
+			return glob[:j], glob[j+len(Separator):], true
+		}
+		i = j + 1
+	}
+}
+
+// Match returns true if the slash separated name matches the glob, where **
+// matches any number of directories.
+func Match(glob, name string) (bool, error) {
+	patterns := strings.Split(glob, "/")
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return false, errors.Wrapf(err, "invalid glob %q", glob)
+		}
+	}
+	return match(patterns, strings.Split(name, "/")), nil
+}
+
+func match(patterns, parts []string) bool {
+	if len(patterns) == 0 {
+		return len(parts) == 0
+	}
+	if patterns[0] == "**" {
+		for i := range parts {
+			if match(patterns[1:], parts[i:]) {
+				return true
+			}
+		}
+		return match(patterns[1:], nil)
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(patterns[0], parts[0]); !ok {
+		return false
+	}
+	return match(patterns[1:], parts[1:])
+}
+
+// File is a file matching a glob.
+type File struct {
+	// Name is the path of the file, or of the archive and the entry within it
+	// joined by the Separator.
+	Name string
+
+	entry io.Reader // the contents of an entry, until the next one is read
+}
+
+// InArchive returns true if the file is an entry within an archive, which can
+// only be opened before the function given to Walk returns unless it is
+// buffered.
+func (f *File) InArchive() bool {
+	return f.entry != nil
+}
+
+// Buffer reads an entry within an archive into memory, so it may be opened
+// after the function given to Walk returns.
+func (f *File) Buffer() error {
+	if f.entry == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(f.entry)
+	if err != nil {
+		return errors.Wrapf(err, "in file %q", f.Name)
+	}
+	f.entry = bytes.NewReader(data)
+	return nil
+}
+
+// gzipFile closes both the decompressor and the file.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// Open returns the contents of the file, decompressed if necessary. Files on
+// disk may be opened concurrently, while an entry within an archive is streamed
+// from it and so may only be opened once.
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.entry != nil {
+		if !isGzip(f.Name) {
+			return ioutil.NopCloser(f.entry), nil
+		}
+		z, err := gzip.NewReader(f.entry)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return z, nil
+	}
+	file, err := os.Open(f.Name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !isGzip(f.Name) {
+		return file, nil
+	}
+	z, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, errors.WithStack(err)
+	}
+	return gzipFile{Reader: z, f: file}, nil
+}
+
+// Walk calls fn with each file matching the glob. Entries within archives are
+// streamed one at a time without being read into memory, so unlike files on
+// disk they must be opened or buffered before fn returns.
+func Walk(glob string, fn func(f *File) error) error {
+	archives, entries, ok := Split(glob)
+	if !ok {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, filename := range matches {
+			if err := fn(&File{Name: filename}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := Match(entries, ""); err != nil {
+		return err
+	}
+	matches, err := filepath.Glob(archives)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, filename := range matches {
+		if strings.HasSuffix(strings.ToLower(filename), ".zip") {
+			err = walkZip(filename, entries, fn)
+		} else {
+			err = walkTar(filename, entries, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(filename, glob string, fn func(f *File) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if lower := strings.ToLower(filename); strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		z, err := gzip.NewReader(file)
+		if err != nil {
+			return errors.Wrapf(err, "in archive %q", filename)
+		}
+		defer z.Close()
+		r = z
+	}
+	t := tar.NewReader(r)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "in archive %q", filename)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(h.Name, "./"))
+		if ok, _ := Match(glob, name); !ok {
+			continue
+		}
+		if err := fn(&File{Name: filename + Separator + name, entry: t}); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(filename, glob string, fn func(f *File) error) error {
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		return errors.Wrapf(err, "in archive %q", filename)
+	}
+	defer z.Close()
+	for _, zf := range z.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(zf.Name, "./"))
+		if ok, _ := Match(glob, name); !ok {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return errors.Wrapf(err, "in archive %q", filename)
+		}
+		err = fn(&File{Name: filename + Separator + name, entry: r})
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/daaku/ensure"
+)
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		glob, archives, entries string
+		ok                      bool
+	}{
+		{glob: "site.tar.gz//**/*.html", archives: "site.tar.gz", entries: "**/*.html", ok: true},
+		{glob: "dist/*.ZIP//*.css", archives: "dist/*.ZIP", entries: "*.css", ok: true},
+		{glob: "a//b.tgz//c", archives: "a//b.tgz", entries: "c", ok: true},
+		{glob: "a//b.html"},
+		{glob: "*.html"},
+	}
+	for _, c := range cases {
+		archives, entries, ok := Split(c.glob)
+		ensure.DeepEqual(t, ok, c.ok, c.glob)
+		ensure.DeepEqual(t, archives, c.archives, c.glob)
+		ensure.DeepEqual(t, entries, c.entries, c.glob)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		glob, name string
+		match      bool
+	}{
+		{glob: "**/*.html", name: "index.html", match: true},
+		{glob: "**/*.html", name: "a/b/index.html", match: true},
+		{glob: "a/**", name: "a/b/c", match: true},
+		{glob: "a/**/c/*.css", name: "a/c/x.css", match: true},
+		{glob: "a/**/c/*.css", name: "a/b/b/c/x.css", match: true},
+		{glob: "*.html", name: "a/index.html"},
+		{glob: "a/**/c", name: "a/b/d"},
+	}
+	for _, c := range cases {
+		match, err := Match(c.glob, c.name)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, match, c.match, c.glob, c.name)
+	}
+	_, err := Match("a/[", "a/b")
+	ensure.Err(t, err, regexp.MustCompile("invalid glob"))
+}
+
+func gz(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	z := gzip.NewWriter(&b)
+	_, err := z.Write(data)
+	ensure.Nil(t, err)
+	ensure.Nil(t, z.Close())
+	return b.Bytes()
+}
+
+var files = []struct {
+	name, body string
+}{
+	{name: "index.html", body: "<p>index</p>"},
+	{name: "blog/post.html", body: "<p>post</p>"},
+	{name: "app.css", body: ".app{}"},
+}
+
+func writeTar(t *testing.T, filename string) {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	ensure.Nil(t, w.WriteHeader(&tar.Header{Name: "./blog/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, f := range files {
+		ensure.Nil(t, w.WriteHeader(&tar.Header{Name: "./" + f.name, Mode: 0644, Size: int64(len(f.body))}))
+		_, err := w.Write([]byte(f.body))
+		ensure.Nil(t, err)
+	}
+	ensure.Nil(t, w.Close())
+	ensure.Nil(t, ioutil.WriteFile(filename, gz(t, b.Bytes()), 0644))
+}
+
+func writeZip(t *testing.T, filename string) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		ensure.Nil(t, err)
+		_, err = fw.Write([]byte(f.body))
+		ensure.Nil(t, err)
+	}
+	fw, err := w.Create("blog/old.html.gz")
+	ensure.Nil(t, err)
+	_, err = fw.Write(gz(t, []byte("<p>old</p>")))
+	ensure.Nil(t, err)
+	ensure.Nil(t, w.Close())
+	ensure.Nil(t, ioutil.WriteFile(filename, b.Bytes(), 0644))
+}
+
+func walk(t *testing.T, glob string) map[string]string {
+	found := make(map[string]string)
+	err := Walk(glob, func(f *File) error {
+		r, err := f.Open()
+		ensure.Nil(t, err)
+		defer r.Close()
+		body, err := ioutil.ReadAll(r)
+		ensure.Nil(t, err)
+		found[f.Name] = string(body)
+		return nil
+	})
+	ensure.Nil(t, err)
+	return found
+}
+
+func TestWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssdalek-archive-")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+	site := filepath.Join(dir, "site.tar.gz")
+	writeTar(t, site)
+	static := filepath.Join(dir, "static.zip")
+	writeZip(t, static)
+	plain := filepath.Join(dir, "plain.html.gz")
+	ensure.Nil(t, ioutil.WriteFile(plain, gz(t, []byte("<p>plain</p>")), 0644))
+
+	ensure.DeepEqual(t, walk(t, site+"//**/*.html"), map[string]string{
+		site + "//index.html":     "<p>index</p>",
+		site + "//blog/post.html": "<p>post</p>",
+	})
+	ensure.DeepEqual(t, walk(t, static+"//blog/*"), map[string]string{
+		static + "//blog/post.html":   "<p>post</p>",
+		static + "//blog/old.html.gz": "<p>old</p>",
+	})
+	ensure.DeepEqual(t, walk(t, filepath.Join(dir, "*.html.gz")), map[string]string{
+		plain: "<p>plain</p>",
+	})
+}
+
+func TestWalkErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssdalek-archive-")
+	ensure.Nil(t, err)
+	defer os.RemoveAll(dir)
+	broken := filepath.Join(dir, "broken.zip")
+	ensure.Nil(t, ioutil.WriteFile(broken, []byte("not a zip"), 0644))
+	err = Walk(broken+"//*", func(*File) error { return nil })
+	ensure.Err(t, err, regexp.MustCompile("in archive"))
+	err = Walk(broken+"//[", func(*File) error { return nil })
+	ensure.Err(t, err, regexp.MustCompile("invalid glob"))
+}
@@ -0,0 +1,130 @@
+// Package cmdusage runs external commands as extractors. The command is given
+// the contents of a file on stdin, with its path in the CSSDALEK_FILENAME
+// environment variable and in place of any {} argument. It writes a stream of
+// JSON objects to stdout, each of which is a node, or words which may be
+// anywhere:
+//
+//	{"tag": "div", "id": "main", "classes": ["card", "open"], "attrs": ["hidden"]}
+//	{"words": ["btn-primary", "btn-default"]}
+//
+// All fields are optional, and a node may also have words. Names are
+// case-insensitive, like they are in HTML. The nodes are read as a single HTML
+// document, and the words as a word file.
+package cmdusage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+	"github.com/daaku/cssdalek/internal/wordusage"
+
+	"github.com/pkg/errors"
+)
+
+// Record is an object in the output of a command.
+type Record struct {
+	Tag     string   `json:"tag"`
+	ID      string   `json:"id"`
+	Classes []string `json:"classes"`
+	Attrs   []string `json:"attrs"`
+	Words   []string `json:"words"`
+}
+
+func (r *Record) isNode() bool {
+	return r.Tag != "" || r.ID != "" || len(r.Classes) > 0 || len(r.Attrs) > 0
+}
+
+func toSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	s := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		s[strings.ToLower(v)] = struct{}{}
+	}
+	return s
+}
+
+// Command is an external extractor.
+type Command struct {
+	Args []string
+	sem  chan struct{}
+}
+
+// New returns the command, which is run at most once per CPU at a time.
+func New(args ...string) *Command {
+	return &Command{Args: args, sem: make(chan struct{}, runtime.NumCPU())}
+}
+
+// Parse returns the command, with arguments separated by whitespace.
+func Parse(command string) (*Command, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.Errorf("invalid empty extractor command")
+	}
+	return New(args...), nil
+}
+
+// Extract runs the command for the file, returning the nodes and the words it
+// writes.
+func (c *Command) Extract(filename string, r io.Reader) (*htmlusage.Info, *wordusage.Info, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = strings.ReplaceAll(arg, "{}", filename)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "CSSDALEK_FILENAME="+filename)
+	cmd.Stdin = r
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, nil, errors.Wrapf(err, "in command %q: %s", c.Args[0], msg)
+		}
+		return nil, nil, errors.Wrapf(err, "in command %q", c.Args[0])
+	}
+	return Decode(&stdout)
+}
+
+// Decode reads the stream of records written by a command.
+func Decode(r io.Reader) (*htmlusage.Info, *wordusage.Info, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	var nodes []cssselector.Selector
+	words := &wordusage.Info{Seen: make(map[string]struct{})}
+	for {
+		var record Record
+		err := d.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid extractor output at offset %d", d.InputOffset())
+		}
+		if record.isNode() {
+			nodes = append(nodes, cssselector.Selector{
+				Tag:   strings.ToLower(record.Tag),
+				ID:    strings.ToLower(record.ID),
+				Class: toSet(record.Classes),
+				Attr:  toSet(record.Attrs),
+			})
+		}
+		for _, w := range record.Words {
+			words.Seen[strings.ToLower(w)] = struct{}{}
+		}
+	}
+	info := new(htmlusage.Info)
+	info.Add(nodes)
+	return info, words, nil
+}
@@ -0,0 +1,102 @@
+package cmdusage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// TestHelperProcess is run as the external command by the other tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("CSSDALEK_HELPER") == "" {
+		return
+	}
+	input, _ := ioutil.ReadAll(os.Stdin)
+	switch os.Args[len(os.Args)-1] {
+	case "echo":
+		fmt.Print(string(input))
+	case "filename":
+		fmt.Printf(`{"words": [%q, %q]}`, os.Getenv("CSSDALEK_FILENAME"), os.Args[len(os.Args)-2])
+	case "fail":
+		fmt.Fprint(os.Stderr, "bad template\n")
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func helper(t *testing.T, mode ...string) *Command {
+	t.Setenv("CSSDALEK_HELPER", "1")
+	return New(append([]string{os.Args[0], "-test.run=TestHelperProcess", "--"}, mode...)...)
+}
+
+func TestExtract(t *testing.T) {
+	c := helper(t, "echo")
+	html, words, err := c.Extract("a.tpl", strings.NewReader(`
+{"tag": "DIV", "id": "main", "classes": ["card", "open"], "attrs": ["hidden"]}
+{"words": ["Btn-Primary"]}
+{"classes": ["orphan"], "words": ["extra"]}
+`))
+	ensure.Nil(t, err)
+	var nodes []cssselector.Selector
+	for _, id := range html.Docs[0] {
+		nodes = append(nodes, html.Nodes[id])
+	}
+	ensure.DeepEqual(t, nodes, []cssselector.Selector{
+		{Tag: "div", ID: "main", Class: set("card", "open"), Attr: set("hidden")},
+		{Class: set("orphan")},
+	})
+	ensure.DeepEqual(t, words.Seen, set("btn-primary", "extra"))
+}
+
+func TestDecodeMixedCase(t *testing.T) {
+	html, _, err := Decode(strings.NewReader(`{"id": "Main", "classes": ["btnPrimary"], "attrs": ["Data-Open"]}`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, html.Nodes, []cssselector.Selector{
+		{ID: "main", Class: set("btnprimary"), Attr: set("data-open")},
+	})
+	chain, err := cssselector.Parse(strings.NewReader("#Main.btnPrimary[data-open]"))
+	ensure.Nil(t, err)
+	ensure.True(t, html.Includes(chain))
+}
+
+func TestFilename(t *testing.T) {
+	c := helper(t, "{}", "filename")
+	_, words, err := c.Extract("views/a.tpl", strings.NewReader(""))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, words.Seen, set("views/a.tpl"))
+}
+
+func TestInvalidOutput(t *testing.T) {
+	c := helper(t, "echo")
+	_, _, err := c.Extract("a.tpl", strings.NewReader(`{"tag": "a"} {"class": "b"}`))
+	ensure.Err(t, err, regexp.MustCompile(`invalid extractor output at offset \d+: json: unknown field "class"`))
+}
+
+func TestCommandError(t *testing.T) {
+	c := helper(t, "fail")
+	_, _, err := c.Extract("a.tpl", strings.NewReader(""))
+	ensure.Err(t, err, regexp.MustCompile(`bad template: exit status 2`))
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(" ./extract  --flag {} ")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, c.Args, []string{"./extract", "--flag", "{}"})
+	_, err = Parse(" ")
+	ensure.Err(t, err, regexp.MustCompile("invalid empty extractor command"))
+}
@@ -1,9 +1,15 @@
-// Package csspurge purges unused CSS.
+// Package csspurge purges unused CSS. Rules can be kept regardless of usage
+// using directives in comments, which are removed from the output:
+//
+//	/* cssdalek-ignore */ keeps the following rule, including any nested rules
+//	/* cssdalek-ignore-start */ keeps the rules up to /* cssdalek-ignore-end */
+//	/* cssdalek-ignore-file */ anywhere in the file keeps all the rules in it
 package csspurge
 
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"log"
 
 	"github.com/daaku/cssdalek/internal/cssselector"
@@ -28,17 +34,51 @@ var (
 	quotesS           = `"'`
 )
 
+const (
+	ignoreDirective      = "cssdalek-ignore"
+	ignoreStartDirective = "cssdalek-ignore-start"
+	ignoreEndDirective   = "cssdalek-ignore-end"
+	ignoreFileDirective  = "cssdalek-ignore-file"
+)
+
 func Purge(u usage.Info, c *cssusage.Info, l *log.Logger, r io.Reader, w io.Writer) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 	p := purger{
-		usageInfo: u,
-		cssInfo:   c,
-		log:       l,
-		parser:    css.NewParser(parse.NewInput(r), false),
-		out:       w,
+		usageInfo:  u,
+		cssInfo:    c,
+		log:        l,
+		parser:     css.NewParser(parse.NewInputBytes(data), false),
+		out:        w,
+		ignoreFile: ignoresFile(data),
 	}
 	return pa.Finish(p.outer)
 }
 
+// directive returns the contents of the comment, which may be a directive.
+func directive(comment []byte) string {
+	return string(bytes.TrimSpace(bytes.TrimSuffix(bytes.TrimPrefix(comment, []byte("/*")), []byte("*/"))))
+}
+
+// ignoresFile returns true if any comment is the file directive. Only the
+// comments are checked, so the directive within a string doesn't count.
+func ignoresFile(data []byte) bool {
+	l := css.NewLexer(parse.NewInputBytes(data))
+	for {
+		tt, text := l.Next()
+		switch tt {
+		case css.ErrorToken:
+			return false
+		case css.CommentToken:
+			if directive(text) == ignoreFileDirective {
+				return true
+			}
+		}
+	}
+}
+
 type purger struct {
 	usageInfo        usage.Info
 	cssInfo          *cssusage.Info
@@ -54,17 +94,53 @@ type purger struct {
 	fontFaceRule     bytes.Buffer
 	fontFaceName     string
 	inKeyframes      bool
+	depth            int  // the number of blocks open
+	deepDepth        int  // the depth of the block whose nested rules are all kept
+	deepMatched      bool // the current ruleset is matched by cssInfo.Deep
+	ignoreNext       bool // the next rule is kept because of a directive
+	ignoreRegion     bool // the rules are kept until the end directive
+	ignoreFile       bool // all the rules are kept
+}
+
+// keepAll returns true if the current rules are kept regardless of usage.
+func (c *purger) keepAll() bool {
+	return c.deepDepth > 0 || c.ignoreRegion || c.ignoreFile
 }
 
 func (c *purger) excludeRuleset() pa.Next {
+	// skip over any nested rules and at-rules too
+	depth := 0
 	for {
 		gt, _, _ := c.parser.Next()
-		if gt == css.EndRulesetGrammar {
-			return c.outer
+		switch gt {
+		case css.ErrorGrammar:
+			return c.error
+		case css.BeginRulesetGrammar, css.BeginAtRuleGrammar:
+			depth++
+		case css.EndAtRuleGrammar:
+			depth--
+		case css.EndRulesetGrammar:
+			if depth == 0 {
+				return c.outer
+			}
+			depth--
 		}
 	}
 }
 
+// end closes a block, ending the deep block if it is the one.
+func (c *purger) end() {
+	if c.depth == c.deepDepth {
+		c.deepDepth = 0
+	}
+	c.depth--
+}
+
+// deep returns true if the chain is matched by cssInfo.Deep.
+func (c *purger) deep(chain cssselector.Chain) bool {
+	return c.cssInfo.Deep != nil && c.cssInfo.Deep.Includes(chain)
+}
+
 func (c *purger) error() pa.Next {
 	err := c.parser.Err()
 	if err == io.EOF {
@@ -81,12 +157,17 @@ func (c *purger) selector() pa.Next {
 
 	selectorBytes := c.scratch.Bytes()
 	include := true
-	if !c.inKeyframes {
+	if c.ignoreNext {
+		c.deepMatched = true
+	} else if !c.inKeyframes && !c.keepAll() {
 		chain, err := cssselector.Parse(bytes.NewReader(selectorBytes))
 		if err != nil {
 			panic(errors.WithMessagef(err, "at offset %d", c.parser.Offset()))
 		}
 		include = c.usageInfo.Includes(chain)
+		if include && c.deep(chain) {
+			c.deepMatched = true
+		}
 	}
 
 	if include {
@@ -116,16 +197,30 @@ func (c *purger) selector() pa.Next {
 func (c *purger) beginRuleset() pa.Next {
 	_ = c.selector()
 
+	c.ignoreNext = false
+
 	// if we haven't included any so far, we're excluding the entire ruleset
 	if !c.selectorIncluded {
+		c.deepMatched = false
 		return c.excludeRuleset
 	}
 
 	// otherwise we just began the ruleset
 	pa.WriteString(c.out, "{")
+	c.depth++
+	if c.deepMatched && c.deepDepth == 0 {
+		c.deepDepth = c.depth
+	}
 
 	// reset
 	c.selectorIncluded = false
+	c.deepMatched = false
+	return c.outer
+}
+
+func (c *purger) endRuleset() pa.Next {
+	pa.Write(c.out, c.data)
+	c.end()
 	return c.outer
 }
 
@@ -150,6 +245,19 @@ func (c *purger) decl() pa.Next {
 }
 
 func (c *purger) comment() pa.Next {
+	switch directive(c.data) {
+	case ignoreDirective:
+		c.ignoreNext = true
+		return c.outer
+	case ignoreStartDirective:
+		c.ignoreRegion = true
+		return c.outer
+	case ignoreEndDirective:
+		c.ignoreRegion = false
+		return c.outer
+	case ignoreFileDirective:
+		return c.outer
+	}
 	if bytes.HasPrefix(c.data, licenseCommentB) || bytes.HasPrefix(c.data, sourceMapCommentB) {
 		pa.Write(c.out, c.data)
 		pa.WriteString(c.out, "\n")
@@ -185,6 +293,10 @@ func (c *purger) beginAtKeyframes() pa.Next {
 	}
 	keyframesName := bytes.TrimSpace(c.scratch.Bytes())
 
+	if c.keepAll() || c.cssInfo.KeepsKeyframes(string(keyframesName)) {
+		c.inKeyframes = true
+		return c.beginAtRuleUnknown
+	}
 	if selectors, found := c.cssInfo.Keyframes[string(keyframesName)]; found {
 		for _, s := range selectors {
 			if c.usageInfo.Includes(s) {
@@ -200,6 +312,7 @@ func (c *purger) beginAtKeyframes() pa.Next {
 func (c *purger) dropUntilEndAtRule() pa.Next {
 	for tt, _, _ := c.parser.Next(); tt != css.EndAtRuleGrammar; tt, _, _ = c.parser.Next() {
 	}
+	c.end()
 	return c.outer
 }
 
@@ -221,7 +334,31 @@ func (c *purger) beginAtRuleUnknown() pa.Next {
 	return c.outer
 }
 
+// atRuleString returns the name and prelude of the current at-rule, separated
+// by a single space, like "@media print".
+func (c *purger) atRuleString() string {
+	c.scratch.Reset()
+	for _, val := range c.parser.Values() {
+		c.scratch.Write(val.Data)
+	}
+	prelude := bytes.TrimSpace(c.scratch.Bytes())
+	if len(prelude) == 0 {
+		return string(c.data)
+	}
+	return string(c.data) + " " + string(prelude)
+}
+
 func (c *purger) beginAtRule() pa.Next {
+	c.depth++
+	if c.ignoreNext {
+		c.ignoreNext = false
+		if c.deepDepth == 0 {
+			c.deepDepth = c.depth
+		}
+	}
+	if c.deepDepth == 0 && len(c.cssInfo.KeepAtRuleRe) > 0 && c.cssInfo.KeepsAtRule(c.atRuleString()) {
+		c.deepDepth = c.depth
+	}
 	if bytes.EqualFold(c.data, atMediaB) || bytes.EqualFold(c.data, atSupportsB) {
 		return c.beginAtMedia
 	}
@@ -236,11 +373,15 @@ func (c *purger) beginAtRule() pa.Next {
 
 func (c *purger) endAtRule() pa.Next {
 	c.inKeyframes = false
+	keepAll := c.keepAll()
+	c.end()
 
 	if c.inFontFace {
 		pa.WriteString(c.out, "}")
 
-		if selectors, found := c.cssInfo.FontFace[c.fontFaceName]; found {
+		if keepAll || c.cssInfo.KeepsFontFace(c.fontFaceName) {
+			io.Copy(c.outSwap, &c.fontFaceRule)
+		} else if selectors, found := c.cssInfo.FontFace[c.fontFaceName]; found {
 			for _, s := range selectors {
 				if c.usageInfo.Includes(s) {
 					io.Copy(c.outSwap, &c.fontFaceRule)
@@ -281,6 +422,8 @@ func (c *purger) outer() pa.Next {
 		return c.selector
 	case css.BeginRulesetGrammar:
 		return c.beginRuleset
+	case css.EndRulesetGrammar:
+		return c.endRuleset
 	case css.DeclarationGrammar, css.CustomPropertyGrammar:
 		return c.decl
 	case css.CommentGrammar:
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -42,6 +43,7 @@ func TestCore(t *testing.T) {
 			ensure.Nil(t, err)
 			cssInfo, err := cssusage.Extract(bytes.NewReader(parts[1]))
 			ensure.Nil(t, err)
+			cssInfo.Merge(&htmlInfo.Style)
 			var actualB bytes.Buffer
 			ensure.Nil(t, Purge(htmlInfo, cssInfo, logger, bytes.NewReader(parts[1]), &actualB))
 			expected := string(minify(t, parts[2]))
@@ -58,6 +60,39 @@ func TestCore(t *testing.T) {
 	}
 }
 
+func TestKeepRe(t *testing.T) {
+	css := `@keyframes spin-fast{to{x:y}}@keyframes fade{to{x:y}}` +
+		`@font-face{font-family:"Icons";src:url(i.woff)}@font-face{font-family:Brand;src:url(b.woff)}`
+	htmlInfo, err := htmlusage.Extract(strings.NewReader(""))
+	ensure.Nil(t, err)
+	cssInfo, err := cssusage.Extract(strings.NewReader(css))
+	ensure.Nil(t, err)
+	cssInfo.KeepKeyframesRe = []*regexp.Regexp{regexp.MustCompile("^spin")}
+	cssInfo.KeepFontFaceRe = []*regexp.Regexp{regexp.MustCompile("^Icons$")}
+	var out bytes.Buffer
+	ensure.Nil(t, Purge(htmlInfo, cssInfo, log.New(ioutil.Discard, "", 0), strings.NewReader(css), &out))
+	ensure.DeepEqual(t, out.String(),
+		`@keyframes spin-fast{to{x:y;}}@font-face{font-family:"Icons";src:url(i.woff);}`)
+}
+
+func TestDeep(t *testing.T) {
+	css := `.modal{color:red;.btn-close{x:y}&:hover{a:b}@media (min-width:1px){.title{a:b}}}` +
+		`.other{.inner{x:y}p{a:b}}.gone{.x{a:b}color:red}.modal-x{a:b}` +
+		`@layer vendor.modal{.x{y:z}}@layer app{.y{y:z}}@media print{.p{a:b}}@media screen{.s{a:b}}`
+	htmlInfo, err := htmlusage.Extract(strings.NewReader(`<div class="modal other"><p></p></div>`))
+	ensure.Nil(t, err)
+	cssInfo, err := cssusage.Extract(strings.NewReader(css))
+	ensure.Nil(t, err)
+	cssInfo.Deep, err = htmlusage.FromSelectors([]string{".modal"})
+	ensure.Nil(t, err)
+	cssInfo.KeepAtRuleRe = []*regexp.Regexp{regexp.MustCompile(`^@layer vendor\.`), regexp.MustCompile(`^@media print$`)}
+	var out bytes.Buffer
+	ensure.Nil(t, Purge(htmlInfo, cssInfo, log.New(ioutil.Discard, "", 0), strings.NewReader(css), &out))
+	ensure.DeepEqual(t, out.String(),
+		`.modal{color:red;.btn-close{x:y;}&:hover{a:b;}@media(min-width:1px){.title{a:b;}}}`+
+			`.other{p{a:b;}}@layer vendor.modal{.x{y:z;}}@layer app{}@media print{.p{a:b;}}`)
+}
+
 func TestReaderError(t *testing.T) {
 	f, err := ioutil.TempFile("", "cssdalek-csspurge-")
 	ensure.Nil(t, err)
@@ -6,6 +6,8 @@ package cssselector
 import (
 	"bytes"
 	"io"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 	"github.com/tdewolff/parse/v2"
@@ -33,6 +35,46 @@ func isExcludedAttr(b []byte) bool {
 	return false
 }
 
+func isHex(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F'
+}
+
+// unescape returns the lowercased identifier with its escapes replaced by the
+// characters they represent, as in md\:flex or \31 0.
+func unescape(ident []byte) string {
+	i := bytes.IndexByte(ident, '\\')
+	if i == -1 {
+		return string(bytes.ToLower(ident))
+	}
+	out := append([]byte(nil), ident[:i]...)
+	for ; i < len(ident); i++ {
+		if ident[i] != '\\' || i+1 == len(ident) {
+			out = append(out, ident[i])
+			continue
+		}
+		i++
+		end := i
+		for end < len(ident) && end-i < 6 && isHex(ident[end]) {
+			end++
+		}
+		if end == i {
+			out = append(out, ident[i])
+			continue
+		}
+		r, _ := strconv.ParseUint(string(ident[i:end]), 16, 32)
+		if r == 0 || r > utf8.MaxRune || r >= 0xD800 && r <= 0xDFFF {
+			r = utf8.RuneError
+		}
+		out = utf8.AppendRune(out, rune(r))
+		// a single whitespace ends the escape
+		if end < len(ident) && (ident[end] == ' ' || ident[end] == '\t' || ident[end] == '\n') {
+			end++
+		}
+		i = end - 1
+	}
+	return string(bytes.ToLower(out))
+}
+
 // Selector is a single parsed selector, a number of which form a chain together.
 type Selector struct {
 	Tag           string
@@ -102,7 +144,7 @@ outer:
 			}
 			return nil, errors.WithStack(err)
 		case css.HashToken:
-			s.ID = string(bytes.ToLower(data[1:])) // drop leading #
+			s.ID = unescape(data[1:]) // drop leading #
 		case css.ColonToken:
 			tt, data := l.Next()
 			switch tt {
@@ -143,7 +185,7 @@ outer:
 				if s.Attr == nil {
 					s.Attr = make(map[string]struct{})
 				}
-				s.Attr[string(bytes.ToLower(next))] = struct{}{}
+				s.Attr[unescape(next)] = struct{}{}
 			}
 			for tt, _ := l.Next(); tt != css.RightBracketToken; tt, _ = l.Next() {
 				if tt == css.ErrorToken {
@@ -165,6 +207,10 @@ outer:
 					tt, data, i.Offset())
 			case '*':
 				continue
+			case '&':
+				// the nesting selector refers to the parent rule, which is
+				// checked on its own
+				continue
 			case '.':
 				tt, next := l.Next()
 				if tt != css.IdentToken {
@@ -175,7 +221,7 @@ outer:
 				if s.Class == nil {
 					s.Class = make(map[string]struct{})
 				}
-				s.Class[string(bytes.ToLower(next))] = struct{}{}
+				s.Class[unescape(next)] = struct{}{}
 			case '>', '+', '~':
 				if !s.IsZero() {
 					chain = append(chain, s)
@@ -183,7 +229,7 @@ outer:
 				}
 			}
 		case css.IdentToken:
-			s.Tag = string(bytes.ToLower(data))
+			s.Tag = unescape(data)
 		case css.WhitespaceToken:
 			if !s.IsZero() {
 				chain = append(chain, s)
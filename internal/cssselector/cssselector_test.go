@@ -210,6 +210,20 @@ func TestValidSelectors(t *testing.T) {
 				{Class: set("first-class")},
 			},
 		},
+		{
+			"class - escaped",
+			`.md\:flex.w-1\/2.bg-\[\#fff\].\31 0.\000041b`,
+			Chain{
+				{Class: set("md:flex", "w-1/2", "bg-[#fff]", "10", "ab")},
+			},
+		},
+		{
+			"hash - escaped",
+			`#a\.b`,
+			Chain{
+				{ID: "a.b"},
+			},
+		},
 		{
 			"class - lowercased",
 			".first-CLASS",
@@ -375,6 +389,21 @@ func TestValidSelectors(t *testing.T) {
 				{},
 			},
 		},
+		{
+			"nesting selector",
+			"&.open > .btn",
+			Chain{
+				{Class: map[string]struct{}{"open": {}}},
+				{Class: map[string]struct{}{"btn": {}}},
+			},
+		},
+		{
+			"nesting selector alone",
+			"&:hover",
+			Chain{
+				{PsuedoClass: []string{"hover"}},
+			},
+		},
 	}
 	for _, c := range cases {
 		c := c
@@ -5,10 +5,12 @@ package cssusage
 import (
 	"bytes"
 	"io"
+	"regexp"
 	"strings"
 
 	"github.com/daaku/cssdalek/internal/cssselector"
 	"github.com/daaku/cssdalek/internal/pa"
+	"github.com/daaku/cssdalek/internal/usage"
 
 	"github.com/pkg/errors"
 	"github.com/tdewolff/parse/v2"
@@ -33,6 +35,7 @@ type extractor struct {
 	currentFontFaces []string
 	currentKeyframes []string
 	scratch          bytes.Buffer
+	inline           bool
 
 	info *Info
 }
@@ -40,6 +43,61 @@ type extractor struct {
 type Info struct {
 	FontFace  map[string][]cssselector.Chain
 	Keyframes map[string][]cssselector.Chain
+
+	// KeepFontFace and KeepKeyframes are the names which should be kept
+	// regardless of selector usage, such as those used in style attributes.
+	KeepFontFace  map[string]struct{}
+	KeepKeyframes map[string]struct{}
+
+	// KeepFontFaceRe and KeepKeyframesRe match more names to keep, such as
+	// those given on the command line.
+	KeepFontFaceRe  []*regexp.Regexp
+	KeepKeyframesRe []*regexp.Regexp
+
+	// Deep matches the rules whose nested rules are all kept regardless of
+	// selector usage, and KeepAtRuleRe the at-rules like "@layer vendor",
+	// given by their name and prelude.
+	Deep         usage.Info
+	KeepAtRuleRe []*regexp.Regexp
+}
+
+func keeps(name string, set map[string]struct{}, res []*regexp.Regexp) bool {
+	if _, found := set[name]; found {
+		return true
+	}
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepsFontFace returns true if the font face should be kept regardless of
+// selector usage.
+func (i *Info) KeepsFontFace(name string) bool {
+	return keeps(name, i.KeepFontFace, i.KeepFontFaceRe)
+}
+
+// KeepsKeyframes returns true if the keyframes should be kept regardless of
+// selector usage.
+func (i *Info) KeepsKeyframes(name string) bool {
+	return keeps(name, i.KeepKeyframes, i.KeepKeyframesRe)
+}
+
+// KeepsAtRule returns true if all the rules nested in the at-rule, given by
+// its name and prelude, should be kept regardless of selector usage.
+func (i *Info) KeepsAtRule(rule string) bool {
+	return keeps(rule, nil, i.KeepAtRuleRe)
+}
+
+func mergeSet(dst *map[string]struct{}, src map[string]struct{}) {
+	if len(src) > 0 && *dst == nil {
+		*dst = make(map[string]struct{})
+	}
+	for k := range src {
+		(*dst)[k] = struct{}{}
+	}
 }
 
 func (i *Info) Merge(other *Info) {
@@ -56,6 +114,18 @@ func (i *Info) Merge(other *Info) {
 	for kf, selectors := range other.Keyframes {
 		i.Keyframes[kf] = append(i.Keyframes[kf], selectors...)
 	}
+
+	mergeSet(&i.KeepFontFace, other.KeepFontFace)
+	mergeSet(&i.KeepKeyframes, other.KeepKeyframes)
+	i.KeepFontFaceRe = append(i.KeepFontFaceRe, other.KeepFontFaceRe...)
+	i.KeepKeyframesRe = append(i.KeepKeyframesRe, other.KeepKeyframesRe...)
+	i.KeepAtRuleRe = append(i.KeepAtRuleRe, other.KeepAtRuleRe...)
+
+	if i.Deep == nil {
+		i.Deep = other.Deep
+	} else if other.Deep != nil {
+		i.Deep = usage.MultiInfo{i.Deep, other.Deep}
+	}
 }
 
 func Extract(r io.Reader) (*Info, error) {
@@ -70,6 +140,33 @@ func Extract(r io.Reader) (*Info, error) {
 	return i, nil
 }
 
+// ExtractDeclarations returns an Info keeping the font faces and keyframes
+// referenced by the declarations, such as those in a style attribute.
+func ExtractDeclarations(r io.Reader) (*Info, error) {
+	i := &Info{}
+	e := &extractor{
+		parser: css.NewParser(parse.NewInput(r), true),
+		inline: true,
+		info:   i,
+	}
+	if err := pa.Finish(e.outer); err != nil {
+		return nil, err
+	}
+	for _, fontFace := range e.currentFontFaces {
+		if i.KeepFontFace == nil {
+			i.KeepFontFace = make(map[string]struct{})
+		}
+		i.KeepFontFace[fontFace] = struct{}{}
+	}
+	for _, kf := range e.currentKeyframes {
+		if i.KeepKeyframes == nil {
+			i.KeepKeyframes = make(map[string]struct{})
+		}
+		i.KeepKeyframes[kf] = struct{}{}
+	}
+	return i, nil
+}
+
 func (c *extractor) error() pa.Next {
 	err := c.parser.Err()
 	if err == io.EOF {
@@ -146,7 +243,7 @@ func (c *extractor) scrUnqStr() string {
 
 func (c *extractor) decl() pa.Next {
 	// decl without selector means we're inside an @ rule
-	if len(c.currentSelectors) == 0 {
+	if len(c.currentSelectors) == 0 && !c.inline {
 		return c.outer
 	}
 
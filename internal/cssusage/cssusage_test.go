@@ -186,6 +186,57 @@ func TestFontFace(t *testing.T) {
 	}
 }
 
+func TestInfoMergeKeep(t *testing.T) {
+	i := Info{}
+	i.Merge(&Info{
+		KeepFontFace:  map[string]struct{}{"a": {}},
+		KeepKeyframes: map[string]struct{}{"b": {}},
+	})
+	i.Merge(&Info{KeepFontFace: map[string]struct{}{"c": {}}})
+	ensure.DeepEqual(t, i, Info{
+		KeepFontFace:  map[string]struct{}{"a": {}, "c": {}},
+		KeepKeyframes: map[string]struct{}{"b": {}},
+	})
+}
+
+func TestExtractDeclarations(t *testing.T) {
+	cases := []struct {
+		name  string
+		css   string
+		faces map[string]struct{}
+		kf    map[string]struct{}
+	}{
+		{
+			name:  "font family",
+			css:   `font-family: 'Brand Sans', serif`,
+			faces: map[string]struct{}{"Brand Sans": {}, "serif": {}},
+		},
+		{
+			name: "animation",
+			css:  `color: red; animation: spin 1s linear`,
+			kf:   map[string]struct{}{"spin": {}, "1s": {}, "linear": {}},
+		},
+		{
+			name: "animation name",
+			css:  `animation-name: "fade in"`,
+			kf:   map[string]struct{}{"fade in": {}},
+		},
+		{
+			name: "nothing interesting",
+			css:  `color: red`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := ExtractDeclarations(strings.NewReader(c.css))
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, info.KeepFontFace, c.faces, "faces")
+			ensure.DeepEqual(t, info.KeepKeyframes, c.kf, "keyframes")
+		})
+	}
+}
+
 func TestReaderError(t *testing.T) {
 	f, err := ioutil.TempFile("", "cssdalek-cssusage-")
 	ensure.Nil(t, err)
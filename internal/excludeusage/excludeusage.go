@@ -0,0 +1,135 @@
+// Package excludeusage drops selectors regardless of their usage, such as
+// those of old browser hacks or components known to be unused.
+package excludeusage
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/pkg/errors"
+)
+
+// Excluder reports the chains which should be dropped.
+type Excluder interface {
+	Excludes(chain cssselector.Chain) bool
+}
+
+type MultiExcluder []Excluder
+
+func (e MultiExcluder) Excludes(chain cssselector.Chain) bool {
+	for _, ee := range e {
+		if ee.Excludes(chain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludeClass excludes chains with a class matching any of the regexps.
+type ExcludeClass struct {
+	Re []*regexp.Regexp
+}
+
+func (e *ExcludeClass) Excludes(chain cssselector.Chain) bool {
+	for _, s := range chain {
+		for c := range s.Class {
+			for _, r := range e.Re {
+				if r.MatchString(c) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ExcludeID excludes chains with an ID matching any of the regexps.
+type ExcludeID struct {
+	Re []*regexp.Regexp
+}
+
+func (e *ExcludeID) Excludes(chain cssselector.Chain) bool {
+	for _, s := range chain {
+		if s.ID == "" {
+			continue
+		}
+		for _, r := range e.Re {
+			if r.MatchString(s.ID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExcludeSelector excludes chains containing any of the selectors. A chain
+// contains a selector if each of its parts is matched in order by a part of
+// the chain with at least its tag, ID, classes and attributes. So .legacy
+// excludes div.legacy and .legacy .title, but not .legacy-title.
+type ExcludeSelector struct {
+	Chains []cssselector.Chain
+}
+
+// FromSelectors returns the exclusion for the selectors.
+func FromSelectors(ss []string) (*ExcludeSelector, error) {
+	var e ExcludeSelector
+	for _, s := range ss {
+		chain, err := cssselector.Parse(strings.NewReader(s))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid selector: %q", s)
+		}
+		e.Chains = append(e.Chains, chain)
+	}
+	return &e, nil
+}
+
+func subset(a, b map[string]struct{}) bool {
+	for k := range a {
+		if _, found := b[k]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches returns true if s has at least the parts of p.
+func matches(p, s *cssselector.Selector) bool {
+	return (p.Tag == "" || p.Tag == s.Tag) && (p.ID == "" || p.ID == s.ID) &&
+		subset(p.Class, s.Class) && subset(p.Attr, s.Attr)
+}
+
+func (e *ExcludeSelector) Excludes(chain cssselector.Chain) bool {
+	for _, excluded := range e.Chains {
+		if len(excluded) == 0 {
+			continue
+		}
+		next := 0
+		for i := range chain {
+			if matches(&excluded[next], &chain[i]) {
+				next++
+				if next == len(excluded) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Info drops the chains matched by Exclude before checking Usage, unless they
+// are matched by Keep.
+type Info struct {
+	Exclude Excluder
+	Keep    usage.Info
+	Usage   usage.Info
+}
+
+func (i *Info) Includes(chain cssselector.Chain) bool {
+	if i.Exclude.Excludes(chain) && !i.Keep.Includes(chain) {
+		return false
+	}
+	return i.Usage.Includes(chain)
+}
@@ -0,0 +1,66 @@
+package excludeusage
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/usage"
+	"github.com/daaku/ensure"
+)
+
+type includer bool
+
+func (i includer) Includes(chain cssselector.Chain) bool {
+	return bool(i)
+}
+
+func parse(t *testing.T, s string) cssselector.Chain {
+	chain, err := cssselector.Parse(strings.NewReader(s))
+	ensure.Nil(t, err)
+	return chain
+}
+
+func TestExcludes(t *testing.T) {
+	class := &ExcludeClass{Re: []*regexp.Regexp{regexp.MustCompile("^ie8-")}}
+	id := &ExcludeID{Re: []*regexp.Regexp{regexp.MustCompile("^legacy$")}}
+	selector, err := FromSelectors([]string{".widget", "div.old .title"})
+	ensure.Nil(t, err)
+	cases := []struct {
+		e        Excluder
+		s        string
+		excluded bool
+	}{
+		{e: class, s: "html .ie8-fix", excluded: true},
+		{e: class, s: ".fix-ie8-"},
+		{e: id, s: "body #legacy a", excluded: true},
+		{e: id, s: "#legacy-nav"},
+		{e: selector, s: "div.widget", excluded: true},
+		{e: selector, s: ".widget .close", excluded: true},
+		{e: selector, s: ".widget-close"},
+		{e: selector, s: "div.old.x > p .title", excluded: true},
+		{e: selector, s: ".title .old"},
+		{e: MultiExcluder{class, id}, s: "#legacy", excluded: true},
+		{e: MultiExcluder{class, id}, s: ".fine"},
+	}
+	for _, c := range cases {
+		ensure.DeepEqual(t, c.e.Excludes(parse(t, c.s)), c.excluded, c.s)
+	}
+}
+
+func TestInvalidSelector(t *testing.T) {
+	_, err := FromSelectors([]string{"a #"})
+	ensure.Err(t, err, regexp.MustCompile(`invalid selector: "a #"`))
+}
+
+func TestInfo(t *testing.T) {
+	exclude := &ExcludeClass{Re: []*regexp.Regexp{regexp.MustCompile("^old")}}
+	i := Info{Exclude: exclude, Keep: usage.MultiInfo{}, Usage: includer(true)}
+	ensure.False(t, i.Includes(parse(t, ".old-button")))
+	ensure.True(t, i.Includes(parse(t, ".new-button")))
+	i.Keep = includer(true)
+	ensure.True(t, i.Includes(parse(t, ".old-button")))
+	i.Usage = includer(false)
+	ensure.False(t, i.Includes(parse(t, ".new-button")))
+}
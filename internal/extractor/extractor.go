@@ -0,0 +1,130 @@
+// Package extractor provides a registry of named extractors, which read usage
+// information from input files. Extractors are selected by name, or by the
+// extension of the file.
+package extractor
+
+import (
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/pkg/errors"
+)
+
+// Extractor reads the usage information in a file.
+type Extractor interface {
+	Extract(filename string, r io.Reader) (usage.Info, error)
+}
+
+// Func is an Extractor implemented by a function.
+type Func func(filename string, r io.Reader) (usage.Info, error)
+
+func (f Func) Extract(filename string, r io.Reader) (usage.Info, error) {
+	return f(filename, r)
+}
+
+// Registry holds extractors by name, and the extensions they handle.
+// Registration is not safe for concurrent use with lookups.
+type Registry struct {
+	extractors map[string]Extractor
+	extensions map[string]string
+}
+
+// Default is the registry extractors registered with Register are added to.
+var Default = new(Registry)
+
+// Register adds the extractor to the Default registry.
+func Register(name string, e Extractor, extensions ...string) {
+	Default.Register(name, e, extensions...)
+}
+
+// normalize returns the lowercased extension with a leading dot.
+func normalize(extension string) string {
+	extension = strings.ToLower(extension)
+	if !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+	return extension
+}
+
+// Register adds the extractor, replacing any with the same name, and uses it
+// for files with the given extensions.
+func (r *Registry) Register(name string, e Extractor, extensions ...string) {
+	if r.extractors == nil {
+		r.extractors = make(map[string]Extractor)
+		r.extensions = make(map[string]string)
+	}
+	r.extractors[name] = e
+	for _, ext := range extensions {
+		r.extensions[normalize(ext)] = name
+	}
+}
+
+// Include adds the extractors and extensions from other, replacing those with
+// the same names.
+func (r *Registry) Include(other *Registry) {
+	for name, e := range other.extractors {
+		r.Register(name, e)
+	}
+	for ext, name := range other.extensions {
+		r.extensions[ext] = name
+	}
+}
+
+// Names returns the names of the extractors in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.extractors))
+	for name := range r.extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the extractor with the given name.
+func (r *Registry) Get(name string) (Extractor, error) {
+	e, found := r.extractors[name]
+	if !found {
+		return nil, errors.Errorf("unknown extractor %q, expected one of: %s",
+			name, strings.Join(r.Names(), ", "))
+	}
+	return e, nil
+}
+
+// Map uses the named extractor for files with the extension.
+func (r *Registry) Map(extension, name string) error {
+	if _, err := r.Get(name); err != nil {
+		return err
+	}
+	r.extensions[normalize(extension)] = name
+	return nil
+}
+
+// ParseMapping parses and applies a mapping like ".tpl=html".
+func (r *Registry) ParseMapping(mapping string) error {
+	i := strings.IndexByte(mapping, '=')
+	if i < 1 || i == len(mapping)-1 {
+		return errors.Errorf("invalid extractor mapping %q, expected one like .tpl=html", mapping)
+	}
+	return r.Map(strings.TrimSpace(mapping[:i]), strings.TrimSpace(mapping[i+1:]))
+}
+
+// ForFile returns the extractor for the file, using the longest extension it
+// has which is mapped to one. This allows extensions like .blade.php to be
+// distinguished from .php. Files compressed with gzip use the extractor for the
+// extension before .gz.
+func (r *Registry) ForFile(filename string) (Extractor, error) {
+	base := strings.TrimSuffix(strings.ToLower(filepath.Base(filename)), ".gz")
+	for i := 0; i < len(base); i++ {
+		if base[i] != '.' {
+			continue
+		}
+		if name, found := r.extensions[base[i:]]; found {
+			return r.Get(name)
+		}
+	}
+	return nil, errors.Errorf("no extractor for file %q, use --extractor to pick one", filename)
+}
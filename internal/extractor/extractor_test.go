@@ -0,0 +1,68 @@
+package extractor
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/daaku/ensure"
+)
+
+type named string
+
+func (n named) Includes(chain cssselector.Chain) bool {
+	return false
+}
+
+func fixed(name string) Extractor {
+	return Func(func(string, io.Reader) (usage.Info, error) {
+		return named(name), nil
+	})
+}
+
+func extract(t *testing.T, r *Registry, filename string) usage.Info {
+	e, err := r.ForFile(filename)
+	ensure.Nil(t, err)
+	info, err := e.Extract(filename, strings.NewReader(""))
+	ensure.Nil(t, err)
+	return info
+}
+
+func TestForFile(t *testing.T) {
+	var r Registry
+	r.Register("html", fixed("html"), ".html", "HTM")
+	r.Register("php", fixed("php"), ".php")
+	r.Register("blade", fixed("blade"), ".blade.php")
+	ensure.DeepEqual(t, extract(t, &r, "a/b.html"), named("html"))
+	ensure.DeepEqual(t, extract(t, &r, "B.HTM"), named("html"))
+	ensure.DeepEqual(t, extract(t, &r, "view.blade.php"), named("blade"))
+	ensure.DeepEqual(t, extract(t, &r, "index.php"), named("php"))
+	ensure.DeepEqual(t, extract(t, &r, "site.tar.gz//a/index.html.gz"), named("html"))
+	_, err := r.ForFile("a.tpl")
+	ensure.Err(t, err, regexp.MustCompile(`no extractor for file "a.tpl"`))
+}
+
+func TestParseMapping(t *testing.T) {
+	var r Registry
+	r.Register("html", fixed("html"), ".html")
+	ensure.Nil(t, r.ParseMapping(".tpl=html"))
+	ensure.Nil(t, r.ParseMapping("htm = html"))
+	ensure.DeepEqual(t, extract(t, &r, "a.tpl"), named("html"))
+	ensure.DeepEqual(t, extract(t, &r, "a.htm"), named("html"))
+	ensure.Err(t, r.ParseMapping(".tpl"), regexp.MustCompile("invalid extractor mapping"))
+	ensure.Err(t, r.ParseMapping(".tpl=twig"), regexp.MustCompile(`unknown extractor "twig", expected one of: html`))
+}
+
+func TestInclude(t *testing.T) {
+	var r, custom Registry
+	r.Register("html", fixed("html"), ".html", ".tpl")
+	custom.Register("tpl", fixed("tpl"), ".tpl")
+	r.Include(&custom)
+	ensure.DeepEqual(t, r.Names(), []string{"html", "tpl"})
+	ensure.DeepEqual(t, extract(t, &r, "a.tpl"), named("tpl"))
+	ensure.DeepEqual(t, extract(t, &r, "a.html"), named("html"))
+}
@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/gomponentsusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = gomponentsusage.Extract(bytes.NewReader(b))
+	return 0
+}
@@ -0,0 +1,301 @@
+// Package gomponentsusage extracts usage information from Go code using the
+// gomponents HTML builder. Calls to the element functions like Div are nodes,
+// and the calls to attribute functions like Class and ID in their arguments
+// add to them.
+package gomponentsusage
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/pkg/errors"
+)
+
+// elements are the names of the HTML elements, some of which have an El
+// suffix in gomponents to avoid clashing with attributes.
+var elements = map[string]bool{}
+
+// attrs are names which are attributes even though they are also elements.
+var attrs = map[string]bool{
+	"data":  true,
+	"style": true,
+	"title": true,
+}
+
+// functions in the gomponents package, which shadow elements when both
+// packages are dot imported.
+var functions = map[string]bool{
+	"Attr":  true,
+	"El":    true,
+	"Group": true,
+	"If":    true,
+	"Iff":   true,
+	"Map":   true,
+	"Raw":   true,
+	"Rawf":  true,
+	"Text":  true,
+	"Textf": true,
+}
+
+func init() {
+	for _, e := range strings.Fields(`
+		a abbr address area article aside audio b base bdi bdo blockquote body br
+		button canvas caption cite code col colgroup data datalist dd del details
+		dfn dialog div dl dt em embed fieldset figcaption figure footer form h1 h2
+		h3 h4 h5 h6 head header hgroup hr html i iframe img input ins kbd label
+		legend li link main map mark menu meta meter nav noscript object ol
+		optgroup option output p param picture pre progress q rp rt ruby s samp
+		script search section select slot small source span strong style sub
+		summary sup svg table tbody td template textarea tfoot th thead time title
+		tr track u ul var video wbr`) {
+		elements[e] = true
+	}
+}
+
+type extractor struct {
+	html       string // the name the html package is imported as
+	gomponents string // the name the gomponents package is imported as
+	components string // the name the components package is imported as
+	nodes      []cssselector.Selector
+}
+
+// call returns the package and function name for a call.
+func call(c *ast.CallExpr) (string, string) {
+	switch fun := c.Fun.(type) {
+	case *ast.Ident:
+		return ".", fun.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := fun.X.(*ast.Ident); ok {
+			return pkg.Name, fun.Sel.Name
+		}
+	}
+	return "", ""
+}
+
+// literals returns the lowercased fields in the string literals within n.
+func literals(n ast.Node) []string {
+	var values []string
+	ast.Inspect(n, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				values = append(values, strings.Fields(strings.ToLower(s))...)
+			}
+		}
+		return true
+	})
+	return values
+}
+
+// first returns the first string literal argument.
+func first(c *ast.CallExpr) string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	if lit, ok := c.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		if s, err := strconv.Unquote(lit.Value); err == nil {
+			return strings.ToLower(s)
+		}
+	}
+	return ""
+}
+
+// element returns the tag if the call creates an element.
+func (e *extractor) element(c *ast.CallExpr) (string, bool) {
+	pkg, name := call(c)
+	if pkg == "" {
+		return "", false
+	}
+	if pkg == e.gomponents && name == "El" {
+		return first(c), true
+	}
+	if pkg != e.html || (pkg == e.gomponents && functions[name]) {
+		return "", false
+	}
+	if strings.HasSuffix(name, "El") {
+		name = strings.TrimSuffix(name, "El")
+	} else if attrs[strings.ToLower(name)] {
+		return "", false
+	}
+	name = strings.ToLower(name)
+	return name, elements[name]
+}
+
+// attr returns the attribute name and the values if the call creates an
+// attribute. The values are only returned for classes and IDs.
+func (e *extractor) attr(c *ast.CallExpr) (string, []string, bool) {
+	pkg, name := call(c)
+	switch {
+	case pkg == "":
+		return "", nil, false
+	case pkg == e.gomponents && name == "Attr":
+		name = first(c)
+		if (name == "class" || name == "id") && len(c.Args) > 1 {
+			return name, literals(c.Args[1]), true
+		}
+		return name, nil, name != ""
+	case pkg != e.html || (pkg == e.gomponents && functions[name]):
+		return "", nil, false
+	case name == "Data" || name == "Aria":
+		return strings.ToLower(name) + "-" + first(c), nil, true
+	}
+	if strings.HasSuffix(name, "Attr") {
+		name = strings.ToLower(strings.TrimSuffix(name, "Attr"))
+	} else {
+		name = strings.ToLower(name)
+		if elements[name] && !attrs[name] {
+			return "", nil, false
+		}
+	}
+	if name == "class" || name == "id" {
+		return name, literals(c), true
+	}
+	return name, nil, true
+}
+
+// isClasses returns true for the components.Classes map type.
+func (e *extractor) isClasses(t ast.Expr) bool {
+	switch t := t.(type) {
+	case *ast.Ident:
+		return e.components == "." && t.Name == "Classes"
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		return ok && pkg.Name == e.components && t.Sel.Name == "Classes"
+	}
+	return false
+}
+
+// apply adds the attribute to the node, returning the IDs it may have.
+func apply(node *cssselector.Selector, name string, values []string, ids []string) []string {
+	switch name {
+	case "class":
+		if node.Class == nil {
+			node.Class = make(map[string]struct{})
+		}
+		for _, v := range values {
+			node.Class[v] = struct{}{}
+		}
+	case "id":
+		ids = append(ids, values...)
+	default:
+		if node.Attr == nil {
+			node.Attr = make(map[string]struct{})
+		}
+		node.Attr[name] = struct{}{}
+	}
+	return ids
+}
+
+// emit adds the node, once for each of the IDs it may have.
+func (e *extractor) emit(node cssselector.Selector, ids []string) {
+	if len(ids) == 0 {
+		e.nodes = append(e.nodes, node)
+		return
+	}
+	for _, id := range ids {
+		node.ID = id
+		e.nodes = append(e.nodes, node)
+	}
+}
+
+// visit finds the elements and attributes within n. Attributes are added to
+// the node if it isn't nil, or are nodes of their own otherwise. Other calls,
+// like gomponents.If, are looked through.
+func (e *extractor) visit(n ast.Node, node *cssselector.Selector, ids *[]string) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if tag, ok := e.element(n); ok {
+				child := cssselector.Selector{Tag: tag}
+				var childIDs []string
+				at := len(e.nodes)
+				for _, arg := range n.Args {
+					e.visit(arg, &child, &childIDs)
+				}
+				// keep the element before its children
+				rest := append([]cssselector.Selector(nil), e.nodes[at:]...)
+				e.nodes = e.nodes[:at]
+				e.emit(child, childIDs)
+				e.nodes = append(e.nodes, rest...)
+				return false
+			}
+			if name, values, ok := e.attr(n); ok {
+				if node == nil {
+					var orphan cssselector.Selector
+					e.emit(orphan, apply(&orphan, name, values, nil))
+				} else {
+					*ids = apply(node, name, values, *ids)
+				}
+				return false
+			}
+		case *ast.CompositeLit:
+			if e.isClasses(n.Type) {
+				var classes []string
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						classes = append(classes, literals(kv.Key)...)
+					}
+				}
+				if node == nil {
+					var orphan cssselector.Selector
+					apply(&orphan, "class", classes, nil)
+					e.emit(orphan, nil)
+				} else {
+					apply(node, "class", classes, nil)
+				}
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Extract returns the usage information for a Go file using gomponents.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var e extractor
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var target *string
+		switch {
+		case strings.HasSuffix(path, "gomponents/html"):
+			target = &e.html
+		case strings.HasSuffix(path, "gomponents/components"):
+			target = &e.components
+		case strings.HasSuffix(path, "gomponents"):
+			target = &e.gomponents
+		default:
+			continue
+		}
+		*target = path[strings.LastIndexByte(path, '/')+1:]
+		if spec.Name != nil {
+			*target = spec.Name.Name
+		}
+	}
+
+	info := new(htmlusage.Info)
+	if e.html != "" || e.gomponents != "" {
+		e.visit(file, nil, nil)
+	}
+	info.Add(e.nodes)
+	return info, nil
+}
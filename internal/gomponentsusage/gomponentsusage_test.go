@@ -0,0 +1,130 @@
+package gomponentsusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		seen []cssselector.Selector
+	}{
+		{
+			name: "elements and attributes",
+			src: `package views
+
+import (
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+func Page(title string) g.Node {
+	return h.Div(h.Class("page Wide"), h.ID("main"),
+		h.TitleEl(g.Text(title)),
+		h.A(h.Href("/"), h.Title("home"), h.Data("id", "1"), g.Text("Home")),
+		h.StyleEl(g.Raw("")),
+	)
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "div", ID: "main", Class: set("page", "wide")},
+				{Tag: "title"},
+				{Tag: "a", Attr: set("href", "title", "data-id")},
+				{Tag: "style"},
+			},
+		},
+		{
+			name: "dot import and conditionals",
+			src: `package views
+
+import (
+	. "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+	c "maragu.dev/gomponents/components"
+)
+
+func List(items []string, open bool) Node {
+	return Ul(
+		If(open, Class("open")),
+		c.Classes{"list": true, "empty": len(items) == 0},
+		Map(items, func(i string) Node {
+			return Li(Class("item"), Text(i))
+		}),
+		El("my-el", Attr("class", "custom"), Attr("x-data")),
+	)
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "ul", Class: set("open", "list", "empty")},
+				{Tag: "li", Class: set("item")},
+				{Tag: "my-el", Class: set("custom"), Attr: set("x-data")},
+			},
+		},
+		{
+			name: "attributes outside elements",
+			src: `package views
+
+import h "github.com/maragu/gomponents/html"
+
+func btn() g.Node { return h.Class("btn") }
+`,
+			seen: []cssselector.Selector{
+				{Class: set("btn")},
+			},
+		},
+		{
+			name: "without gomponents",
+			src: `package views
+
+func Div(x string) {}
+
+func main() { Div("x") }
+`,
+			seen: nil,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.src))
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+func TestInvalidGo(t *testing.T) {
+	_, err := Extract(strings.NewReader(`package`))
+	ensure.Err(t, err, regexp.MustCompile("expected"))
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-gomponentsusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
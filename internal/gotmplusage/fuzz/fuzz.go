@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/gotmplusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = gotmplusage.Extract(bytes.NewReader(b))
+	return 0
+}
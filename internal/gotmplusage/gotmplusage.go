@@ -0,0 +1,222 @@
+// Package gotmplusage extracts usage information from Go html/template files.
+// Actions are masked out so the literals in all branches are seen by the HTML
+// extractor, and the bodies of define and block actions are extracted as
+// fragments which may be nested in any document.
+package gotmplusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	leftDelimB  = []byte("{{")
+	rightDelimB = []byte("}}")
+	commentB    = []byte("/*")
+	endCommentB = []byte("*/")
+)
+
+// actions which are closed by an end action.
+var openers = map[string]bool{
+	"if":     true,
+	"range":  true,
+	"with":   true,
+	"define": true,
+	"block":  true,
+}
+
+type scanner struct {
+	data []byte
+	pos  int
+
+	// out is the stack of outputs. The document is at the bottom, and each open
+	// action has an entry which is the fragment for define and block actions,
+	// or the output the action is nested in otherwise.
+	out       []*bytes.Buffer
+	fragments []*bytes.Buffer
+}
+
+func (s *scanner) current() *bytes.Buffer {
+	return s.out[len(s.out)-1]
+}
+
+// mask writes spaces for the data, keeping the newlines.
+func (s *scanner) mask(data []byte) {
+	w := s.current()
+	for _, b := range data {
+		if b == '\n' {
+			w.WriteByte(b)
+		} else {
+			w.WriteByte(' ')
+		}
+	}
+}
+
+// literal writes the contents of a string literal, replacing anything which
+// cannot be part of a name with spaces so it cannot change the markup.
+func (s *scanner) literal(data []byte) {
+	w := s.current()
+	for _, b := range data {
+		if isNameByte(b) {
+			w.WriteByte(b)
+		} else {
+			w.WriteByte(' ')
+		}
+	}
+}
+
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b >= 0x80
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// scan processes all of the data, splitting it into the document and
+// fragments.
+func (s *scanner) scan() {
+	for s.pos < len(s.data) {
+		i := bytes.Index(s.data[s.pos:], leftDelimB)
+		if i == -1 {
+			s.current().Write(s.data[s.pos:])
+			return
+		}
+		s.current().Write(s.data[s.pos : s.pos+i])
+		s.pos += i
+		s.action()
+	}
+}
+
+// action processes the action at the current position.
+func (s *scanner) action() {
+	start := s.pos
+	body := start + len(leftDelimB)
+	if body+1 < len(s.data) && s.data[body] == '-' && isSpace(s.data[body+1]) {
+		body += 2
+	}
+	for body < len(s.data) && isSpace(s.data[body]) {
+		body++
+	}
+
+	// comments may contain anything until they end
+	if bytes.HasPrefix(s.data[body:], commentB) {
+		end := bytes.Index(s.data[body:], endCommentB)
+		if end == -1 {
+			s.mask(s.data[start:])
+			s.pos = len(s.data)
+			return
+		}
+		s.pos = body + end + len(endCommentB)
+		if close := bytes.Index(s.data[s.pos:], rightDelimB); close != -1 {
+			s.pos += close + len(rightDelimB)
+		} else {
+			s.pos = len(s.data)
+		}
+		s.mask(s.data[start:s.pos])
+		return
+	}
+
+	keyword := body
+	for keyword < len(s.data) && s.data[keyword] >= 'a' && s.data[keyword] <= 'z' {
+		keyword++
+	}
+	switch name := string(s.data[body:keyword]); {
+	case name == "end":
+		s.mask(s.data[start:body])
+		s.rest()
+		if len(s.out) > 1 {
+			s.out = s.out[:len(s.out)-1]
+		}
+		return
+	case openers[name]:
+		s.mask(s.data[start:body])
+		s.rest()
+		if name == "define" || name == "block" {
+			fragment := new(bytes.Buffer)
+			s.fragments = append(s.fragments, fragment)
+			s.out = append(s.out, fragment)
+		} else {
+			s.out = append(s.out, s.current())
+		}
+		return
+	}
+	s.mask(s.data[start:body])
+	s.rest()
+}
+
+// rest processes the remainder of an action up to and including the right
+// delimiter, keeping the contents of string literals.
+func (s *scanner) rest() {
+	for s.pos < len(s.data) {
+		switch b := s.data[s.pos]; b {
+		case '}':
+			if bytes.HasPrefix(s.data[s.pos:], rightDelimB) {
+				s.mask(rightDelimB)
+				s.pos += len(rightDelimB)
+				return
+			}
+		case '"', '`', '\'':
+			s.quoted(b)
+			continue
+		}
+		if s.pos < len(s.data) {
+			s.mask(s.data[s.pos : s.pos+1])
+			s.pos++
+		}
+	}
+}
+
+// quoted processes a string, raw string or character literal.
+func (s *scanner) quoted(quote byte) {
+	s.mask(s.data[s.pos : s.pos+1])
+	s.pos++
+	start := s.pos
+	for s.pos < len(s.data) && s.data[s.pos] != quote {
+		if quote != '`' && s.data[s.pos] == '\\' && s.pos+1 < len(s.data) {
+			s.pos++
+		}
+		s.pos++
+	}
+	if quote == '\'' {
+		s.mask(s.data[start:s.pos])
+	} else {
+		s.literal(s.data[start:s.pos])
+	}
+	if s.pos < len(s.data) {
+		s.mask(s.data[s.pos : s.pos+1])
+		s.pos++
+	}
+}
+
+// Extract returns the usage information for a Go html/template file. The
+// classes, IDs and tags in all branches of conditional actions, as well as
+// those in string literals, are considered seen.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var doc bytes.Buffer
+	s := scanner{data: data, out: []*bytes.Buffer{&doc}}
+	s.scan()
+
+	info, err := htmlusage.ExtractWith(&doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, fragment := range s.fragments {
+		fi, err := htmlusage.ExtractWith(fragment, nil)
+		if err != nil {
+			return nil, err
+		}
+		info.MergeFragments(fi)
+	}
+	return info, nil
+}
@@ -0,0 +1,153 @@
+package gotmplusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/daaku/ensure"
+)
+
+func seen(t testing.TB, selectors ...string) []cssselector.Selector {
+	var parsed []cssselector.Selector
+	for _, s := range selectors {
+		p, err := cssselector.Parse(strings.NewReader(s))
+		ensure.Nil(t, err, "for selector", s)
+		parsed = append(parsed, p...)
+	}
+	return parsed
+}
+
+func nodes(i *htmlusage.Info, ids []int32) []cssselector.Selector {
+	var nodes []cssselector.Selector
+	for _, id := range ids {
+		nodes = append(nodes, i.Nodes[id])
+	}
+	return nodes
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		tmpl string
+		seen []cssselector.Selector
+	}{
+		{
+			name: "plain html",
+			tmpl: `<a class="foo">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "all branches",
+			tmpl: `<a class="btn {{if .Primary}}btn-primary{{else}}btn-default{{end}}">`,
+			seen: seen(t, "a.btn.btn-primary.btn-default"),
+		},
+		{
+			name: "field in class",
+			tmpl: `<a class="{{.Class}} foo">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "string literals",
+			tmpl: `<a class="{{or .Class "foo bar"}}" id="{{printf ` + "`main`" + `}}">`,
+			seen: seen(t, "a#main.foo.bar"),
+		},
+		{
+			name: "braces in strings",
+			tmpl: `<a class="{{"}}"}} foo">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "quotes in strings",
+			tmpl: `<a class="{{"x\" y"}}">`,
+			seen: seen(t, "a.x.y"),
+		},
+		{
+			name: "character literal",
+			tmpl: `<a class="{{print '}'}} foo">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "trim markers",
+			tmpl: `<a class="{{- if .X -}} foo {{- end -}}">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "comment",
+			tmpl: `<a {{/* <b class="c"> }} */}} class="foo">`,
+			seen: seen(t, "a.foo"),
+		},
+		{
+			name: "attributes in actions",
+			tmpl: `<a {{if .X}}class="foo"{{else}}id="bar"{{end}}>`,
+			seen: seen(t, "a#bar.foo"),
+		},
+		{
+			name: "id branches",
+			tmpl: `<a id="{{if .X}}foo{{else}}bar{{end}}">`,
+			seen: seen(t, "a#foo", "a#bar"),
+		},
+		{
+			name: "tags in branches",
+			tmpl: `{{range .Items}}<li>{{else}}<p class="empty">{{end}}`,
+			seen: seen(t, "li", "p.empty"),
+		},
+		{
+			name: "unterminated action",
+			tmpl: `<a class="foo">{{if .X`,
+			seen: seen(t, "a.foo"),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.tmpl))
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, nodes(info, info.Docs[0]), c.seen)
+			ensure.DeepEqual(t, len(info.Fragments), 0)
+		})
+	}
+}
+
+func TestExtractFragments(t *testing.T) {
+	info, err := Extract(strings.NewReader(`
+{{define "nav"}}<nav class="menu">{{if .Open}}<a class="open">{{end}}</nav>{{end}}
+<body class="page">
+	{{block "footer" .}}<footer>{{end}}
+	{{template "nav" .}}
+</body>
+`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, nodes(info, info.Docs[0]), seen(t, "body.page"))
+	ensure.DeepEqual(t, nodes(info, info.Fragments), seen(t, "nav.menu", "a.open", "footer"))
+
+	var i htmlusage.Info
+	i.Merge(info)
+	other, err := Extract(strings.NewReader(`<main class="other">{{template "nav"}}`))
+	ensure.Nil(t, err)
+	i.Merge(other)
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, ".other .menu .open"))))
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, ".page footer"))))
+	ensure.False(t, i.Includes(cssselector.Chain(seen(t, ".page .other"))))
+}
+
+func TestExtractFragmentsOnly(t *testing.T) {
+	info, err := Extract(strings.NewReader(`{{define "a"}}<a class="x">{{end}}{{define "b"}}<b class="y">{{end}}`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(info.Docs[0]), 0)
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, ".x .y"))))
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-gotmplusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
@@ -0,0 +1,97 @@
+// Package htmlpurge purges unused CSS from the <style> elements in HTML.
+package htmlpurge
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/daaku/cssdalek/internal/csspurge"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/pkg/errors"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/html"
+)
+
+var styleB = []byte("style")
+
+// span is the range of bytes containing the contents of a <style> element.
+type span struct {
+	start, end int
+}
+
+// styles returns the spans of the contents of all <style> elements.
+func styles(doc []byte) ([]span, error) {
+	// the lexer lowercases tag names in place, so give it a copy
+	i := parse.NewInputBytes(append([]byte(nil), doc...))
+	l := html.NewLexer(i)
+	var spans []span
+	inStyle := false
+	for {
+		start := i.Offset()
+		tt, _ := l.Next()
+		switch tt {
+		case html.ErrorToken:
+			err := l.Err()
+			if err == io.EOF {
+				return spans, nil
+			}
+			return nil, errors.WithMessagef(err, "at offset %d", i.Offset())
+		case html.StartTagToken:
+			inStyle = bytes.Equal(l.Text(), styleB)
+		case html.TextToken:
+			if inStyle {
+				spans = append(spans, span{start: start, end: i.Offset()})
+			}
+			inStyle = false
+		case html.EndTagToken:
+			inStyle = false
+		}
+	}
+}
+
+// Purge copies the HTML from r to w, purging the contents of each <style>
+// element using the given usage information. Everything outside of the <style>
+// elements is copied unchanged. The font faces and keyframes kept by c, which
+// may be nil, are kept along with those used in any of the <style> elements.
+// c is not modified.
+func Purge(u usage.Info, c *cssusage.Info, l *log.Logger, r io.Reader, w io.Writer) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	spans, err := styles(doc)
+	if err != nil {
+		return err
+	}
+
+	// font-face and keyframes may be used from any of the <style> elements
+	var cssInfo cssusage.Info
+	if c != nil {
+		cssInfo.Merge(c)
+	}
+	for _, s := range spans {
+		info, err := cssusage.Extract(bytes.NewReader(doc[s.start:s.end]))
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", s.start)
+		}
+		cssInfo.Merge(info)
+	}
+
+	last := 0
+	for _, s := range spans {
+		if _, err := w.Write(doc[last:s.start]); err != nil {
+			return errors.WithStack(err)
+		}
+		err := csspurge.Purge(u, &cssInfo, l, bytes.NewReader(doc[s.start:s.end]), w)
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", s.start)
+		}
+		last = s.end
+	}
+	_, err = w.Write(doc[last:])
+	return errors.WithStack(err)
+}
@@ -0,0 +1,74 @@
+package htmlpurge
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/daaku/ensure"
+)
+
+func TestPurge(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "no style",
+			in:   `<!DOCTYPE html><A HREF=x>Hi</A>`,
+			out:  `<!DOCTYPE html><A HREF=x>Hi</A>`,
+		},
+		{
+			name: "unused rule",
+			in:   "<HEAD><STYLE media=all>\n.a { color: red }\n.b { color: blue }\n</STYLE></HEAD>\n<P CLASS=a>Hi</P>",
+			out:  "<HEAD><STYLE media=all>.a{color:red;}</STYLE></HEAD>\n<P CLASS=a>Hi</P>",
+		},
+		{
+			name: "multiple styles",
+			in:   `<style>i{color:red}</style><i>x</i><style>b{color:red}</style >`,
+			out:  `<style>i{color:red;}</style><i>x</i><style></style >`,
+		},
+		{
+			name: "font face from another style",
+			in:   `<style>@font-face{font-family:Foo}</style><style>i{font-family:Foo}</style><i>`,
+			out:  `<style>@font-face{font-family:Foo;}</style><style>i{font-family:Foo;}</style><i>`,
+		},
+		{
+			name: "keyframes from style attribute",
+			in:   `<style>@keyframes spin{to{color:red}}</style><i style="animation: spin 1s">`,
+			out:  `<style>@keyframes spin{to{color:red;}}</style><i style="animation: spin 1s">`,
+		},
+		{
+			name: "style inside textarea",
+			in:   `<textarea><style>.x{}</style></textarea>`,
+			out:  `<textarea><style>.x{}</style></textarea>`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := htmlusage.Extract(strings.NewReader(c.in))
+			ensure.Nil(t, err)
+			var out bytes.Buffer
+			l := log.New(ioutil.Discard, "", 0)
+			ensure.Nil(t, Purge(info, &info.Style, l, strings.NewReader(c.in), &out))
+			ensure.DeepEqual(t, out.String(), c.out)
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-htmlpurge-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	err = Purge(nil, nil, nil, f, ioutil.Discard)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
@@ -3,38 +3,440 @@ package htmlusage
 
 import (
 	"bytes"
+	stdhtml "html"
 	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/wordusage"
 
 	"github.com/pkg/errors"
 	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
 	"github.com/tdewolff/parse/v2/html"
 )
 
 var (
-	idB    = []byte("id")
-	classB = []byte("class")
+	idB         = []byte("id")
+	classB      = []byte("class")
+	linkB       = []byte("link")
+	relB        = []byte("rel")
+	hrefB       = []byte("href")
+	stylesheetB = []byte("stylesheet")
+	styleB      = []byte("style")
+	scriptB     = []byte("script")
+	onB         = []byte("on")
+	dataB       = []byte("data-")
+	atImportB   = []byte("@import")
+	quotesS     = `"'`
 )
 
+// Info holds the nodes seen in HTML documents. Identical nodes are only stored
+// once, and each document refers to the distinct nodes seen within it. A chain
+// is only considered included if all of its parts are found within the same
+// document.
+//
+// Includes builds an index over the documents on demand, and is not safe for
+// concurrent use.
 type Info struct {
-	Seen []cssselector.Selector
+	// Nodes holds every distinct node, keeping only the Tag, ID, Class and Attr.
+	Nodes []cssselector.Selector
+	// Docs holds the indexes into Nodes of the nodes seen in each document.
+	Docs [][]int32
+	// Words holds the words seen in the scripts, event handlers and data
+	// attributes of each document. Scripts may add any of them to any node in
+	// the same document. Documents without any words may be omitted from the
+	// end.
+	Words []map[string]struct{}
+	// Patterns holds the patterns of the classes scripts build dynamically in
+	// each document, like 'btn-' + variant. Scripts may add any class matching
+	// them to any node in the same document. Documents without any patterns
+	// may be omitted from the end.
+	Patterns []map[wordusage.Pattern]struct{}
+	// Stylesheets holds the targets of <link rel="stylesheet"> elements and
+	// @import rules in <style> elements, as they were written.
+	Stylesheets []string
+	// Style keeps the font faces and keyframes referenced in style attributes.
+	Style cssusage.Info
+	// Fragments holds the indexes into Nodes of the nodes seen in fragments,
+	// such as template definitions, which may be nested in any document.
+	Fragments []int32
+
+	inFragments map[int32]struct{}
+	nodeIndex   map[string]int32
+	strings     map[string]string
+	sig         []byte
+	sigKeys     []string
+	index       index
+}
+
+// Add adds a document containing the given nodes.
+func (i *Info) Add(nodes []cssselector.Selector) {
+	doc := make([]int32, 0, len(nodes))
+	inDoc := make(map[int32]struct{}, len(nodes))
+	for n := range nodes {
+		id := i.node(&nodes[n])
+		if _, found := inDoc[id]; !found {
+			inDoc[id] = struct{}{}
+			doc = append(doc, id)
+		}
+	}
+	i.Docs = append(i.Docs, doc)
 }
 
 func (i *Info) Merge(other *Info) {
-	i.Seen = append(i.Seen, other.Seen...)
+	i.Stylesheets = append(i.Stylesheets, other.Stylesheets...)
+	i.Style.Merge(&other.Style)
+	ids := make([]int32, len(other.Nodes))
+	for n := range other.Nodes {
+		ids[n] = i.node(&other.Nodes[n])
+	}
+	for d, otherDoc := range other.Docs {
+		doc := make([]int32, len(otherDoc))
+		for n, id := range otherDoc {
+			doc[n] = ids[id]
+		}
+		i.Docs = append(i.Docs, doc)
+		i.setWords(len(i.Docs)-1, other.words(d))
+		i.AddPatterns(len(i.Docs)-1, other.patterns(d))
+	}
+	for _, id := range other.Fragments {
+		i.addFragment(ids[id])
+	}
+}
+
+// MergeFragments merges all the documents in other as fragments, which may be
+// nested in any document. Their words and patterns, such as from scripts
+// within them, are added to the documents already in i.
+func (i *Info) MergeFragments(other *Info) {
+	i.Stylesheets = append(i.Stylesheets, other.Stylesheets...)
+	i.Style.Merge(&other.Style)
+	for n := range other.Nodes {
+		i.addFragment(i.node(&other.Nodes[n]))
+	}
+	for d := range other.Docs {
+		for doc := range i.Docs {
+			i.AddWords(doc, other.words(d))
+			i.AddPatterns(doc, other.patterns(d))
+		}
+	}
+}
+
+func (i *Info) addFragment(id int32) {
+	if _, found := i.inFragments[id]; found {
+		return
+	}
+	if i.inFragments == nil {
+		i.inFragments = make(map[int32]struct{})
+	}
+	i.inFragments[id] = struct{}{}
+	i.Fragments = append(i.Fragments, id)
+}
+
+// words returns the words seen in the given document.
+func (i *Info) words(doc int) map[string]struct{} {
+	if doc >= 0 && doc < len(i.Words) {
+		return i.Words[doc]
+	}
+	return nil
+}
+
+// AddWords adds words seen in the given document, which may be added to any of
+// its nodes, such as classes added by scripts. The words must be added before
+// the document is used for matching.
+func (i *Info) AddWords(doc int, words map[string]struct{}) {
+	if len(words) == 0 {
+		return
+	}
+	merged := make(map[string]struct{}, len(i.words(doc))+len(words))
+	for w := range i.words(doc) {
+		merged[w] = struct{}{}
+	}
+	for w := range words {
+		merged[w] = struct{}{}
+	}
+	i.setWords(doc, merged)
+}
+
+// patterns returns the class patterns seen in the given document.
+func (i *Info) patterns(doc int) map[wordusage.Pattern]struct{} {
+	if doc >= 0 && doc < len(i.Patterns) {
+		return i.Patterns[doc]
+	}
+	return nil
+}
+
+// AddPatterns adds the patterns of the classes scripts build dynamically in
+// the given document. Like words, the patterns must be added before the
+// document is used for matching.
+func (i *Info) AddPatterns(doc int, patterns map[wordusage.Pattern]struct{}) {
+	if len(patterns) == 0 {
+		return
+	}
+	for len(i.Patterns) <= doc {
+		i.Patterns = append(i.Patterns, nil)
+	}
+	if i.Patterns[doc] == nil {
+		i.Patterns[doc] = make(map[wordusage.Pattern]struct{}, len(patterns))
+	}
+	for p := range patterns {
+		i.Patterns[doc][p] = struct{}{}
+	}
+}
+
+// SortedPatterns returns the patterns of all documents in order.
+func (i *Info) SortedPatterns() []wordusage.Pattern {
+	var all wordusage.Info
+	for _, patterns := range i.Patterns {
+		all.Merge(&wordusage.Info{Patterns: patterns})
+	}
+	return all.SortedPatterns()
+}
+
+// added is what scripts may add to any node in a document: the words, and the
+// classes matching the patterns.
+type added struct {
+	words    map[string]struct{}
+	patterns map[wordusage.Pattern]struct{}
+}
+
+// added returns what scripts may add to the nodes in the given document.
+func (i *Info) added(doc int) added {
+	return added{words: i.words(doc), patterns: i.patterns(doc)}
+}
+
+func (a added) none() bool {
+	return a.words == nil && a.patterns == nil
+}
+
+// has returns true if the token may be added, either as a word or, for
+// classes, by a matching pattern.
+func (a added) has(token string, class bool) bool {
+	if contains(a.words, token) {
+		return true
+	}
+	if class {
+		for p := range a.patterns {
+			if p.Matches(token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (i *Info) setWords(doc int, words map[string]struct{}) {
+	if len(words) == 0 {
+		return
+	}
+	for len(i.Words) <= doc {
+		i.Words = append(i.Words, nil)
+	}
+	i.Words[doc] = i.internSet(words)
+}
+
+// node returns the index of the given node in Nodes, adding it if it hasn't
+// been seen before.
+func (i *Info) node(s *cssselector.Selector) int32 {
+	i.sig = append(i.sig[:0], s.Tag...)
+	i.sig = append(i.sig, 0)
+	i.sig = append(i.sig, s.ID...)
+	i.sig = i.appendSigKeys(append(i.sig, 0), s.Class)
+	i.sig = i.appendSigKeys(append(i.sig, 0), s.Attr)
+	if id, found := i.nodeIndex[string(i.sig)]; found {
+		return id
+	}
+
+	node := cssselector.Selector{
+		Tag:   i.intern(s.Tag),
+		ID:    i.intern(s.ID),
+		Class: i.internSet(s.Class),
+		Attr:  i.internSet(s.Attr),
+	}
+	id := int32(len(i.Nodes))
+	i.Nodes = append(i.Nodes, node)
+	if i.nodeIndex == nil {
+		i.nodeIndex = make(map[string]int32)
+	}
+	i.nodeIndex[string(i.sig)] = id
+	return id
 }
 
+func (i *Info) appendSigKeys(sig []byte, set map[string]struct{}) []byte {
+	i.sigKeys = i.sigKeys[:0]
+	for k := range set {
+		i.sigKeys = append(i.sigKeys, k)
+	}
+	sort.Strings(i.sigKeys)
+	for _, k := range i.sigKeys {
+		sig = append(sig, k...)
+		sig = append(sig, 1)
+	}
+	return sig
+}
+
+func (i *Info) intern(s string) string {
+	if s == "" {
+		return ""
+	}
+	if interned, found := i.strings[s]; found {
+		return interned
+	}
+	if i.strings == nil {
+		i.strings = make(map[string]string)
+	}
+	i.strings[s] = s
+	return s
+}
+
+func (i *Info) internSet(set map[string]struct{}) map[string]struct{} {
+	if len(set) == 0 {
+		return nil
+	}
+	interned := make(map[string]struct{}, len(set))
+	for k := range set {
+		interned[i.intern(k)] = struct{}{}
+	}
+	return interned
+}
+
+// Includes returns true if every part of the chain matches a node in the same
+// document. Only the documents containing a candidate node for the rarest part
+// are checked, and within them only the candidate nodes sharing the rarest
+// token of each part. The index is updated first, so Includes is not safe for
+// concurrent use.
 func (i *Info) Includes(chain cssselector.Chain) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	i.index.update(i)
+
+	// only documents containing a candidate for the rarest selector need to be
+	// checked. if any selector has no candidates at all, nothing can match.
+	all := make([]candidates, len(chain))
+	rarest := &all[0]
+	for ci := range chain {
+		all[ci] = i.index.candidates(i, &chain[ci])
+		if all[ci].len() < rarest.len() {
+			rarest = &all[ci]
+		}
+	}
+
+	if rarest.all {
+		return i.scanIncludes(chain)
+	}
+
+	var checked map[int32]struct{}
+	check := func(doc int32) bool {
+		if _, found := checked[doc]; found {
+			return false
+		}
+		if i.candidatesInclude(doc, chain, all) {
+			return true
+		}
+		if checked == nil {
+			checked = make(map[int32]struct{})
+		}
+		checked[doc] = struct{}{}
+		return false
+	}
+	for _, id := range rarest.nodes {
+		// with words or patterns, the node may still match once they are added
+		if len(i.Words) == 0 && len(i.Patterns) == 0 && !rarest.selector.Matches(&i.Nodes[id]) {
+			continue
+		}
+		// fragments may be nested in any document
+		if _, found := i.inFragments[id]; found {
+			return i.scanIncludes(chain)
+		}
+		for _, doc := range i.index.docsOf[id] {
+			if check(doc) {
+				return true
+			}
+		}
+	}
+	for _, doc := range rarest.wordDocs {
+		if check(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidatesInclude is like docIncludes, but each selector is only checked
+// against its candidate nodes in the document, unless there are fewer nodes in
+// the document than candidates.
+func (i *Info) candidatesInclude(doc int32, chain cssselector.Chain, all []candidates) bool {
+	a := i.added(int(doc))
+	for ci := range chain {
+		if !i.candidatesMatch(doc, &chain[ci], &all[ci], a) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Info) candidatesMatch(doc int32, s *cssselector.Selector, c *candidates, a added) bool {
+	if !a.none() && wordsMatch(s, a) {
+		return true
+	}
+	nodes := i.Docs[doc]
+	// once the token is added, nodes without it may match too
+	if c.all || a.has(c.token, c.class) || len(c.nodes) > len(nodes)+len(i.Fragments) {
+		for _, id := range nodes {
+			if matches(s, &i.Nodes[id], a) {
+				return true
+			}
+		}
+		for _, id := range i.Fragments {
+			if matches(s, &i.Nodes[id], a) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range c.nodes {
+		if _, found := i.inFragments[id]; !found && !i.index.contains(id, doc) {
+			continue
+		}
+		if matches(s, &i.Nodes[id], a) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanIncludes checks every document. If there are only fragments, they are
+// checked on their own.
+func (i *Info) scanIncludes(chain cssselector.Chain) bool {
+	for doc := range i.Docs {
+		if i.docIncludes(doc, chain) {
+			return true
+		}
+	}
+	if len(i.Docs) == 0 && len(i.Fragments) > 0 {
+		return i.docIncludes(-1, chain)
+	}
+	return false
+}
+
+// docIncludes checks the given document along with the fragments. A negative
+// document checks only the fragments.
+func (i *Info) docIncludes(doc int, chain cssselector.Chain) bool {
+	var nodes []int32
+	if doc >= 0 {
+		nodes = i.Docs[doc]
+	}
+	a := i.added(doc)
 	pending := len(chain)
 	found := make([]bool, pending)
-	for _, node := range i.Seen {
+	if !a.none() {
 		for i, selector := range chain {
-			if found[i] {
-				continue
-			}
-			if selector.Matches(&node) {
+			if wordsMatch(&selector, a) {
 				pending--
 				if pending == 0 {
 					return true
@@ -44,28 +446,171 @@ func (i *Info) Includes(chain cssselector.Chain) bool {
 			}
 		}
 	}
+	for _, id := range nodes {
+		if docIncludesNode(chain, found, &pending, &i.Nodes[id], a) {
+			return true
+		}
+	}
+	for _, id := range i.Fragments {
+		if docIncludesNode(chain, found, &pending, &i.Nodes[id], a) {
+			return true
+		}
+	}
 	return false
 }
 
+// docIncludesNode marks the selectors matching the node as found, and returns
+// true once none are pending.
+func docIncludesNode(chain cssselector.Chain, found []bool, pending *int, node *cssselector.Selector, a added) bool {
+	for i, selector := range chain {
+		if found[i] {
+			continue
+		}
+		if matches(&selector, node, a) {
+			*pending--
+			if *pending == 0 {
+				return true
+			}
+
+			found[i] = true
+		}
+	}
+	return false
+}
+
+// matches returns true if the selector matches the node, once anything scripts
+// may add is added to it.
+func matches(s, node *cssselector.Selector, a added) bool {
+	if a.none() {
+		return s.Matches(node)
+	}
+	if s.Tag != "" && s.Tag != node.Tag {
+		return false
+	}
+	if s.ID != "" && s.ID != node.ID && !a.has(s.ID, false) {
+		return false
+	}
+	for class := range s.Class {
+		if !contains(node.Class, class) && !a.has(class, true) {
+			return false
+		}
+	}
+	for attr := range s.Attr {
+		if !contains(node.Attr, attr) && !a.has(attr, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordsMatch returns true if all parts of the selector may be added by
+// scripts, such as for elements created by them.
+func wordsMatch(s *cssselector.Selector, a added) bool {
+	if s.Tag == "" && s.ID == "" && len(s.Class) == 0 && len(s.Attr) == 0 {
+		return false
+	}
+	if s.Tag != "" && !a.has(s.Tag, false) {
+		return false
+	}
+	if s.ID != "" && !a.has(s.ID, false) {
+		return false
+	}
+	for class := range s.Class {
+		if !a.has(class, true) {
+			return false
+		}
+	}
+	for attr := range s.Attr {
+		if !a.has(attr, false) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(set map[string]struct{}, k string) bool {
+	_, found := set[k]
+	return found
+}
+
+// FromSelectors returns an Info with a single document containing the nodes
+// from all the given selectors.
 func FromSelectors(ss []string) (*Info, error) {
-	var i Info
+	var seen []cssselector.Selector
 	for _, s := range ss {
 		sel, err := cssselector.Parse(strings.NewReader(s))
 		if err != nil {
 			return nil, errors.WithMessagef(err, "invalid selector: %q", s)
 		}
-		i.Seen = append(i.Seen, sel...)
+		seen = append(seen, sel...)
 	}
+	var i Info
+	i.Add(seen)
 	return &i, nil
 }
 
+// AttrFunc returns the name and value to use for an attribute, allowing
+// template bindings to be read as plain attributes. The value is given without
+// its quotes.
+type AttrFunc func(name, value []byte) ([]byte, []byte)
+
+// unquote removes the quotes around an attribute value.
+func unquote(value []byte) []byte {
+	if len(value) >= 2 && value[0] == value[len(value)-1] && (value[0] == '"' || value[0] == '\'') {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Extract returns an Info with a single document containing the nodes found in
+// the HTML. Repeated nodes are only recorded once.
 func Extract(r io.Reader) (*Info, error) {
+	return extract(r, nil, false, false)
+}
+
+// ExtractWith is like Extract, but for templates. Attributes are first passed
+// through attr if it isn't nil, and an id attribute may have several
+// alternatives separated by whitespace, such as from the branches of a
+// conditional.
+func ExtractWith(r io.Reader, attr AttrFunc) (*Info, error) {
+	return extract(r, attr, true, false)
+}
+
+// ExtractComponent is like ExtractWith, but for the templates of components
+// like those of Vue and Svelte. Elements whose names start with an uppercase
+// letter or contain a dot are other components, and are read as nodes without
+// a tag since they may render any element.
+func ExtractComponent(r io.Reader, attr AttrFunc) (*Info, error) {
+	return extract(r, attr, true, true)
+}
+
+// isComponent returns true if the element name refers to a component.
+func isComponent(name []byte) bool {
+	return len(name) > 0 && ((name[0] >= 'A' && name[0] <= 'Z') || bytes.IndexByte(name, '.') != -1)
+}
+
+func extract(r io.Reader, attr AttrFunc, idAlternatives, components bool) (*Info, error) {
+	// the lexer lowercases element names in place, so components are found in
+	// a copy of the input
+	var src []byte
+	if components {
+		var err error
+		src, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		r = bytes.NewReader(append([]byte(nil), src...))
+	}
 	i := parse.NewInput(r)
 	l := html.NewLexer(i)
-	var seenNodes []cssselector.Selector
+	var info Info
+	doc := []int32{}
+	inDoc := make(map[int32]struct{})
+	inStyle, inScript := false, false
+	var scripts bytes.Buffer
 docloop:
 	for {
-		tt, _ := l.Next()
+		tt, data := l.Next()
 		switch tt {
 		case html.ErrorToken:
 			err := l.Err()
@@ -73,10 +618,28 @@ docloop:
 				break docloop
 			}
 			return nil, errors.WithMessagef(err, "at offset %d", i.Offset())
+		case html.TextToken:
+			if inStyle {
+				info.Stylesheets = append(info.Stylesheets, imports(l.Text())...)
+			}
+			if inScript {
+				scripts.Write(l.Text())
+				scripts.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			inStyle, inScript = false, false
 		case html.StartTagToken:
 			tag := cssselector.Selector{
 				Tag: string(bytes.ToLower(l.Text())),
 			}
+			if components && isComponent(src[i.Offset()-len(data)+1:i.Offset()]) {
+				tag.Tag = ""
+			}
+			inStyle = tag.Tag == string(styleB)
+			inScript = tag.Tag == string(scriptB)
+			isLink := tag.Tag == string(linkB)
+			var rel, href []byte
+			var ids [][]byte
 		tagloop:
 			for {
 				ttAttr, _ := l.Next()
@@ -84,15 +647,47 @@ docloop:
 				default:
 					return nil, errors.Errorf("unexpected token type %s at offset %d", ttAttr, i.Offset())
 				case html.AttributeToken:
-					name := l.Text()
+					name, val := l.Text(), l.AttrVal()
+					if attr != nil {
+						name, val = attr(name, unquote(val))
+					}
+					if isLink {
+						if bytes.EqualFold(name, relB) {
+							rel = bytes.Trim(val, quotesS)
+						} else if bytes.EqualFold(name, hrefB) {
+							href = bytes.TrimSpace(bytes.Trim(val, quotesS))
+						}
+					}
+					if isScriptAttr(name) {
+						scripts.Write(val)
+						scripts.WriteByte('\n')
+					}
+					if bytes.EqualFold(name, styleB) {
+						decls := stdhtml.UnescapeString(string(bytes.Trim(val, quotesS)))
+						// browsers ignore malformed style attributes, and so do we
+						if style, err := cssusage.ExtractDeclarations(strings.NewReader(decls)); err == nil {
+							info.Style.Merge(style)
+						}
+					}
 					if bytes.EqualFold(name, idB) {
-						tag.ID = string(bytes.ToLower(bytes.Trim(l.AttrVal(), `"'`)))
+						id := bytes.ToLower(bytes.Trim(val, `"'`))
+						tag.ID = string(id)
+						if idAlternatives {
+							// an ID is a single name, but templates may have
+							// several alternatives in the value
+							ids = bytes.Fields(id)
+							tag.ID = ""
+							if len(ids) > 0 {
+								tag.ID = string(ids[0])
+							}
+						}
 					} else if bytes.EqualFold(name, classB) {
-						classes := bytes.Fields(l.AttrVal())
-						tag.Class = make(map[string]struct{})
+						classes := bytes.Fields(bytes.Trim(val, `"'`))
+						if tag.Class == nil {
+							tag.Class = make(map[string]struct{})
+						}
 						for _, c := range classes {
-							c := bytes.ToLower(bytes.Trim(c, `"'`))
-							tag.Class[string(c)] = struct{}{}
+							tag.Class[string(bytes.ToLower(c))] = struct{}{}
 						}
 					} else {
 						if tag.Attr == nil {
@@ -100,15 +695,108 @@ docloop:
 						}
 						tag.Attr[string(bytes.ToLower(name))] = struct{}{}
 					}
-				case html.StartTagCloseToken:
+				case html.StartTagCloseToken, html.StartTagVoidToken:
 					break tagloop
 				}
 			}
-			seenNodes = append(seenNodes, tag)
+			if isLink && len(href) > 0 && isStylesheet(rel) {
+				info.Stylesheets = append(info.Stylesheets, string(href))
+			}
+			add := func(tag *cssselector.Selector) {
+				id := info.node(tag)
+				if _, found := inDoc[id]; !found {
+					inDoc[id] = struct{}{}
+					doc = append(doc, id)
+				}
+			}
+			add(&tag)
+			for n := 1; n < len(ids); n++ {
+				tag.ID = string(ids[n])
+				add(&tag)
+			}
 		}
 	}
 
-	return &Info{
-		Seen: seenNodes,
-	}, nil
+	info.Docs = append(info.Docs, doc)
+	if scripts.Len() > 0 {
+		words, err := wordusage.Extract(&scripts)
+		if err != nil {
+			return nil, err
+		}
+		info.setWords(0, words.Seen)
+	}
+	return &info, nil
+}
+
+// isScriptAttr returns true for attributes whose values are likely to be used
+// by scripts, namely event handlers and data attributes.
+func isScriptAttr(name []byte) bool {
+	return (len(name) > len(onB) && bytes.EqualFold(name[:len(onB)], onB)) ||
+		(len(name) > len(dataB) && bytes.EqualFold(name[:len(dataB)], dataB))
+}
+
+func isStylesheet(rel []byte) bool {
+	for _, r := range bytes.Fields(rel) {
+		if bytes.EqualFold(r, stylesheetB) {
+			return true
+		}
+	}
+	return false
+}
+
+// imports returns the targets of the @import rules in the CSS.
+func imports(style []byte) []string {
+	var targets []string
+	l := css.NewLexer(parse.NewInputBytes(style))
+	for {
+		tt, data := l.Next()
+		switch tt {
+		case css.ErrorToken:
+			return targets
+		case css.AtKeywordToken:
+			if !bytes.EqualFold(data, atImportB) {
+				continue
+			}
+			for tt, data = l.Next(); tt == css.WhitespaceToken; tt, data = l.Next() {
+			}
+			switch tt {
+			case css.StringToken:
+				targets = append(targets, string(bytes.Trim(data, quotesS)))
+			case css.URLToken:
+				data = bytes.TrimSuffix(bytes.TrimPrefix(data, []byte("url(")), []byte(")"))
+				targets = append(targets, string(bytes.Trim(bytes.TrimSpace(data), quotesS)))
+			}
+		}
+	}
+}
+
+// Resolve returns the local file a stylesheet target refers to, given the web
+// root and the file containing the reference. Targets on other hosts, or
+// outside the web root, are not resolved.
+func Resolve(root, from, target string) (string, bool) {
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if target == "" || strings.HasPrefix(target, "//") || strings.Contains(target, ":") {
+		return "", false
+	}
+	var resolved string
+	if strings.HasPrefix(target, "/") {
+		resolved = filepath.Join(root, filepath.FromSlash(target))
+	} else {
+		resolved = filepath.Join(filepath.Dir(from), filepath.FromSlash(target))
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	absResolved, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, absResolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return resolved, true
 }
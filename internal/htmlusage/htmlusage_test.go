@@ -1,10 +1,13 @@
 package htmlusage
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 
@@ -23,39 +26,79 @@ func seen(t testing.TB, selectors ...string) []cssselector.Selector {
 	return parsed
 }
 
+func infoOf(docs ...[]cssselector.Selector) *Info {
+	var i Info
+	for _, doc := range docs {
+		i.Add(doc)
+	}
+	return &i
+}
+
+// docNodes returns the nodes seen in the given document.
+func docNodes(i *Info, doc int) []cssselector.Selector {
+	var nodes []cssselector.Selector
+	for _, id := range i.Docs[doc] {
+		nodes = append(nodes, i.Nodes[id])
+	}
+	return nodes
+}
+
 func TestInfoMerge(t *testing.T) {
-	i1 := Info{Seen: seen(t, "a")}
-	i2 := Info{Seen: seen(t, "b")}
-	i1.Merge(&i2)
-	ensure.DeepEqual(t, i1, Info{
-		Seen: []cssselector.Selector{
-			{Tag: "a"},
-			{Tag: "b"},
-		},
+	i1 := infoOf(seen(t, "a"))
+	i2 := infoOf(seen(t, "b"), seen(t, "a b"))
+	i1.Merge(i2)
+	ensure.DeepEqual(t, i1.Nodes, []cssselector.Selector{
+		{Tag: "a"},
+		{Tag: "b"},
 	})
+	ensure.DeepEqual(t, i1.Docs, [][]int32{{0}, {1}, {0, 1}})
+}
+
+func TestInfoAddDeduplicates(t *testing.T) {
+	i := infoOf(seen(t, "a.b", "i", "a.b", "a.b.c"))
+	ensure.DeepEqual(t, i.Nodes, seen(t, "a.b", "i", "a.b.c"))
+	ensure.DeepEqual(t, i.Docs, [][]int32{{0, 1, 2}})
+}
+
+func TestInfoMergeInterns(t *testing.T) {
+	i1, err := Extract(strings.NewReader(`<a class="foo bar"><i class="foo">`))
+	ensure.Nil(t, err)
+	i2, err := Extract(strings.NewReader(`<i class="foo"><a class="bar foo">`))
+	ensure.Nil(t, err)
+	var i Info
+	i.Merge(i1)
+	i.Merge(i2)
+	ensure.DeepEqual(t, len(i.Nodes), 2)
+	ensure.DeepEqual(t, i.Docs, [][]int32{{0, 1}, {1, 0}})
+	ensure.DeepEqual(t, len(i.strings), 4)
 }
 
 func TestInfoIncludes(t *testing.T) {
 	cases := []struct {
 		name  string
-		seen  []cssselector.Selector
+		docs  [][]cssselector.Selector
 		chain cssselector.Chain
 	}{
 		{
 			name:  "simple tag",
-			seen:  seen(t, "a"),
+			docs:  [][]cssselector.Selector{seen(t, "a")},
 			chain: cssselector.Chain(seen(t, "a")),
 		},
 		{
 			name:  "multiple selectors",
-			seen:  seen(t, "a i"),
+			docs:  [][]cssselector.Selector{seen(t, "a i")},
 			chain: cssselector.Chain(seen(t, "a i")),
 		},
+		{
+			name:  "second document",
+			docs:  [][]cssselector.Selector{seen(t, "a"), seen(t, "b i")},
+			chain: cssselector.Chain(seen(t, "b i")),
+		},
 	}
 	for _, c := range cases {
 		c := c
 		t.Run(c.name, func(t *testing.T) {
-			i := Info{Seen: c.seen}
+			i := infoOf(c.docs...)
 			ensure.True(t, i.Includes(c.chain))
 		})
 	}
@@ -64,24 +107,29 @@ func TestInfoIncludes(t *testing.T) {
 func TestInfoNotIncludes(t *testing.T) {
 	cases := []struct {
 		name  string
-		seen  []cssselector.Selector
+		docs  [][]cssselector.Selector
 		chain cssselector.Chain
 	}{
 		{
 			name:  "simple tag",
-			seen:  seen(t, "a"),
+			docs:  [][]cssselector.Selector{seen(t, "a")},
 			chain: cssselector.Chain(seen(t, "b")),
 		},
 		{
 			name:  "multiple selectors",
-			seen:  seen(t, "a i"),
+			docs:  [][]cssselector.Selector{seen(t, "a i")},
 			chain: cssselector.Chain(seen(t, "a b")),
 		},
+		{
+			name:  "split across documents",
+			docs:  [][]cssselector.Selector{seen(t, ".checkout-form"), seen(t, ".promo-banner")},
+			chain: cssselector.Chain(seen(t, ".checkout-form .promo-banner")),
+		},
 	}
 	for _, c := range cases {
 		c := c
 		t.Run(c.name, func(t *testing.T) {
-			i := Info{Seen: c.seen}
+			i := infoOf(c.docs...)
 			ensure.False(t, i.Includes(c.chain))
 		})
 	}
@@ -133,6 +181,16 @@ func TestValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "class with whitespace",
+			html: `<a class=" f  g ">`,
+			seen: seen(t, "a.f.g"),
+		},
+		{
+			name: "void tag",
+			html: `<br/>`,
+			seen: seen(t, "br"),
+		},
 		{
 			name: "attr - lowercased",
 			html: `<A FOO="BAR">`,
@@ -149,11 +207,18 @@ func TestValid(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			info, err := Extract(strings.NewReader(c.html))
 			ensure.Nil(t, err)
-			ensure.DeepEqual(t, info.Seen, c.seen)
+			ensure.DeepEqual(t, docNodes(info, 0), c.seen)
 		})
 	}
 }
 
+func TestExtractDeduplicates(t *testing.T) {
+	info, err := Extract(strings.NewReader(`<a><a class="x y"><a class="y x"><a>`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Nodes, seen(t, "a", "a.x.y"))
+	ensure.DeepEqual(t, info.Docs, [][]int32{{0, 1}})
+}
+
 func TestInvalidHTML(t *testing.T) {
 	_, err := Extract(strings.NewReader(`<a <!--`))
 	ensure.Err(t, err, regexp.MustCompile("unexpected token"))
@@ -172,10 +237,243 @@ func TestFromSelectors(t *testing.T) {
 	selectors := []string{"a", "#foo"}
 	i, err := FromSelectors(selectors)
 	ensure.Nil(t, err)
-	ensure.DeepEqual(t, i, &Info{Seen: seen(t, selectors...)})
+	ensure.DeepEqual(t, docNodes(i, 0), seen(t, selectors...))
 }
 
 func TestFromSelectorsError(t *testing.T) {
 	_, err := FromSelectors([]string{"a #"})
 	ensure.Err(t, err, regexp.MustCompile("unexpected token"))
 }
+
+func TestExtractStylesheets(t *testing.T) {
+	cases := []struct {
+		name        string
+		html        string
+		stylesheets []string
+	}{
+		{
+			name:        "link",
+			html:        `<link rel="stylesheet" href="/a.css">`,
+			stylesheets: []string{"/a.css"},
+		},
+		{
+			name:        "self closing link",
+			html:        `<LINK REL=stylesheet HREF='a.css' />`,
+			stylesheets: []string{"a.css"},
+		},
+		{
+			name:        "multiple rel values",
+			html:        `<link rel="alternate stylesheet" href="a.css">`,
+			stylesheets: []string{"a.css"},
+		},
+		{
+			name: "other rel",
+			html: `<link rel="icon" href="a.ico">`,
+		},
+		{
+			name:        "import",
+			html:        `<style>@import "a.css"; @import url(b.css) print; @import url('c.css');</style>`,
+			stylesheets: []string{"a.css", "b.css", "c.css"},
+		},
+		{
+			name: "import outside style",
+			html: `<p>@import "a.css";</p>`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.html))
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, info.Stylesheets, c.stylesheets)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		target   string
+		resolved string
+	}{
+		{target: "/css/a.css", resolved: "root/css/a.css"},
+		{target: "a.css?v=1", resolved: "root/blog/a.css"},
+		{target: "../a.css#x", resolved: "root/a.css"},
+		{target: "../../a.css"},
+		{target: "https://example.com/a.css"},
+		{target: "//example.com/a.css"},
+		{target: "data:text/css,a{}"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.target, func(t *testing.T) {
+			resolved, ok := Resolve("root", "root/blog/index.html", c.target)
+			ensure.DeepEqual(t, ok, c.resolved != "")
+			ensure.DeepEqual(t, filepath.ToSlash(resolved), c.resolved)
+		})
+	}
+}
+
+func TestExtractWords(t *testing.T) {
+	cases := []struct {
+		name  string
+		html  string
+		words []string
+	}{
+		{
+			name:  "script",
+			html:  `<script>el.classList.add('is-open')</script>`,
+			words: []string{"el", "classlist", "add", "is-open"},
+		},
+		{
+			name:  "json-ld",
+			html:  `<script type="application/ld+json">{"@type": "Article"}</script>`,
+			words: []string{"type", "article"},
+		},
+		{
+			name:  "event handler",
+			html:  `<button onClick="this.classList.toggle('open')">`,
+			words: []string{"this", "classlist", "toggle", "open"},
+		},
+		{
+			name:  "data attribute",
+			html:  `<a data-toggle="dropdown-menu">`,
+			words: []string{"dropdown-menu"},
+		},
+		{
+			name: "text and other attributes",
+			html: `<p title="foo">bar</p>`,
+		},
+		{
+			name: "text after script",
+			html: `<script></script>foo`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.html))
+			ensure.Nil(t, err)
+			var words []string
+			for w := range info.words(0) {
+				words = append(words, w)
+			}
+			sort.Strings(words)
+			sort.Strings(c.words)
+			ensure.DeepEqual(t, words, c.words)
+		})
+	}
+}
+
+func TestIncludesWords(t *testing.T) {
+	i1, err := Extract(strings.NewReader(
+		`<nav class="menu"><button onclick="nav.classList.add('open')">`))
+	ensure.Nil(t, err)
+	i2, err := Extract(strings.NewReader(
+		`<script>document.createElement('dialog').id = 'modal'</script>`))
+	ensure.Nil(t, err)
+	var i Info
+	i.Merge(i1)
+	i.Merge(i2)
+
+	included := []string{".menu.open", "button.open", ".menu .open", "dialog#modal", "*"}
+	for _, s := range included {
+		ensure.True(t, i.Includes(cssselector.Chain(seen(t, s))), s)
+	}
+	excluded := []string{"span.open", ".menu.closed", ".menu #modal", "dialog.open"}
+	for _, s := range excluded {
+		ensure.False(t, i.Includes(cssselector.Chain(seen(t, s))), s)
+	}
+}
+
+func TestExtractStyle(t *testing.T) {
+	info, err := Extract(strings.NewReader(
+		`<a style="font-family: &quot;Brand Sans&quot;"><i STYLE='animation: spin 1s'>`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Style.KeepFontFace, map[string]struct{}{"Brand Sans": {}})
+	ensure.DeepEqual(t, info.Style.KeepKeyframes, map[string]struct{}{"spin": {}, "1s": {}})
+}
+
+func TestExtractMalformedStyle(t *testing.T) {
+	info, err := Extract(strings.NewReader(
+		`<a class="x" style="color: red; }"><i style="animation: spin">`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Nodes[0].Class, map[string]struct{}{"x": {}})
+	ensure.DeepEqual(t, info.Style.KeepKeyframes, map[string]struct{}{"spin": {}})
+}
+
+func TestIncludesFragments(t *testing.T) {
+	var i Info
+	i.Merge(infoOf(seen(t, "body.page"), seen(t, "body.other")))
+	i.MergeFragments(infoOf(seen(t, "nav.menu", "a.link")))
+	ensure.DeepEqual(t, len(i.Fragments), 2)
+
+	included := []string{".page .menu", ".other a.link", ".menu .link", "nav.menu"}
+	for _, s := range included {
+		ensure.True(t, i.Includes(cssselector.Chain(seen(t, s))), s)
+	}
+	excluded := []string{".page .other", ".menu .missing", "i.link"}
+	for _, s := range excluded {
+		ensure.False(t, i.Includes(cssselector.Chain(seen(t, s))), s)
+	}
+}
+
+func TestMergeFragments(t *testing.T) {
+	var fragments Info
+	fragments.MergeFragments(infoOf(seen(t, "a", "b")))
+	fragments.MergeFragments(infoOf(seen(t, "b", "c")))
+	ensure.DeepEqual(t, fragments.Fragments, []int32{0, 1, 2})
+	ensure.True(t, fragments.Includes(cssselector.Chain(seen(t, "a c"))))
+
+	var i Info
+	i.Merge(infoOf(seen(t, "c")))
+	i.Merge(&fragments)
+	ensure.DeepEqual(t, i.Fragments, []int32{1, 2, 0})
+	ensure.DeepEqual(t, i.Docs, [][]int32{{0}})
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, "c a"))))
+}
+
+func TestMergeFragmentsWords(t *testing.T) {
+	i, err := Extract(strings.NewReader(`<p>`))
+	ensure.Nil(t, err)
+	fragment, err := Extract(strings.NewReader(`<a data-state="open"><script>show('tip')</script>`))
+	ensure.Nil(t, err)
+	i.MergeFragments(fragment)
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, "p.open"))))
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, "a.tip"))))
+}
+
+func TestAddWords(t *testing.T) {
+	i := infoOf(seen(t, "a"))
+	i.AddWords(0, map[string]struct{}{"x": {}})
+	i.AddWords(0, map[string]struct{}{"y": {}})
+	i.AddWords(0, nil)
+	ensure.DeepEqual(t, i.Words, []map[string]struct{}{{"x": {}, "y": {}}})
+	ensure.True(t, i.Includes(cssselector.Chain(seen(t, "a.x.y"))))
+}
+
+func TestExtractWith(t *testing.T) {
+	attr := func(name, value []byte) ([]byte, []byte) {
+		if string(name) == ":class" {
+			return []byte("class"), bytes.ToUpper(value)
+		}
+		return name, value
+	}
+	info, err := ExtractWith(strings.NewReader(`<a class="x" :class='y z' b>`), attr)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docNodes(info, 0), []cssselector.Selector{
+		{Tag: "a", Class: set("x", "y", "z"), Attr: set("b")},
+	})
+}
+
+func TestExtractIDAlternatives(t *testing.T) {
+	info, err := Extract(strings.NewReader(`<a id="x y">`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docNodes(info, 0), []cssselector.Selector{{Tag: "a", ID: "x y"}})
+
+	info, err = ExtractWith(strings.NewReader(`<a id="x y">`), nil)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docNodes(info, 0), []cssselector.Selector{
+		{Tag: "a", ID: "x"},
+		{Tag: "a", ID: "y"},
+	})
+}
@@ -0,0 +1,132 @@
+package htmlusage
+
+import (
+	"sort"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+)
+
+// index maps each tag, ID, class and attribute to the distinct nodes
+// containing it, each node to the documents it was seen in, and each word to
+// the documents it was seen in. The documents with patterns are kept apart, as
+// the classes they match are only known when matching.
+type index struct {
+	nodes  int // number of nodes indexed so far
+	docs   int // number of documents indexed so far
+	tag    map[string][]int32
+	id     map[string][]int32
+	class  map[string][]int32
+	attr   map[string][]int32
+	docsOf [][]int32
+	words  map[string][]int32
+
+	patternDocs []int32
+}
+
+// update indexes any nodes and documents added since the last update.
+func (x *index) update(i *Info) {
+	if x.tag == nil {
+		x.tag = make(map[string][]int32)
+		x.id = make(map[string][]int32)
+		x.class = make(map[string][]int32)
+		x.attr = make(map[string][]int32)
+		x.words = make(map[string][]int32)
+	}
+	for ; x.nodes < len(i.Nodes); x.nodes++ {
+		s := &i.Nodes[x.nodes]
+		id := int32(x.nodes)
+		if s.Tag != "" {
+			x.tag[s.Tag] = append(x.tag[s.Tag], id)
+		}
+		if s.ID != "" {
+			x.id[s.ID] = append(x.id[s.ID], id)
+		}
+		for c := range s.Class {
+			x.class[c] = append(x.class[c], id)
+		}
+		for a := range s.Attr {
+			x.attr[a] = append(x.attr[a], id)
+		}
+		x.docsOf = append(x.docsOf, nil)
+	}
+	for ; x.docs < len(i.Docs); x.docs++ {
+		for _, id := range i.Docs[x.docs] {
+			x.docsOf[id] = append(x.docsOf[id], int32(x.docs))
+		}
+		for w := range i.words(x.docs) {
+			x.words[w] = append(x.words[w], int32(x.docs))
+		}
+		if i.patterns(x.docs) != nil {
+			x.patternDocs = append(x.patternDocs, int32(x.docs))
+		}
+	}
+}
+
+// candidates are the nodes, and the documents with words or patterns, which
+// may match a selector.
+type candidates struct {
+	selector *cssselector.Selector
+	token    string
+	class    bool // the token is a class, which patterns may match
+	all      bool // the selector has nothing to index, so every node matches
+	nodes    []int32
+	wordDocs []int32
+}
+
+func (c *candidates) len() int {
+	if c.all {
+		return int(^uint(0) >> 1)
+	}
+	return len(c.nodes) + len(c.wordDocs)
+}
+
+// candidates returns the nodes and documents with words or patterns sharing
+// the rarest token with the selector.
+func (x *index) candidates(i *Info, s *cssselector.Selector) candidates {
+	c := candidates{selector: s, all: true}
+	consider := func(nodes []int32, token string, class bool) {
+		wordDocs := x.words[token]
+		if class {
+			wordDocs = x.classDocs(i, token, wordDocs)
+		}
+		if c.all || len(nodes)+len(wordDocs) < c.len() {
+			c.all = false
+			c.token = token
+			c.class = class
+			c.nodes = nodes
+			c.wordDocs = wordDocs
+		}
+	}
+	if s.Tag != "" {
+		consider(x.tag[s.Tag], s.Tag, false)
+	}
+	if s.ID != "" {
+		consider(x.id[s.ID], s.ID, false)
+	}
+	for class := range s.Class {
+		consider(x.class[class], class, true)
+	}
+	for attr := range s.Attr {
+		consider(x.attr[attr], attr, false)
+	}
+	return c
+}
+
+// classDocs adds the documents with a pattern matching the class to the
+// documents with it in their words.
+func (x *index) classDocs(i *Info, class string, docs []int32) []int32 {
+	for _, doc := range x.patternDocs {
+		if !contains(i.words(int(doc)), class) && i.added(int(doc)).has(class, true) {
+			docs = append(docs[:len(docs):len(docs)], doc)
+		}
+	}
+	return docs
+}
+
+// contains returns true if the node was seen in the document. The documents of
+// each node are indexed in order.
+func (x *index) contains(id, doc int32) bool {
+	docs := x.docsOf[id]
+	n := sort.Search(len(docs), func(n int) bool { return docs[n] >= doc })
+	return n < len(docs) && docs[n] == doc
+}
@@ -0,0 +1,241 @@
+package htmlusage
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/wordusage"
+
+	"github.com/daaku/ensure"
+)
+
+// scanIncludes is the straightforward matcher the index must agree with.
+func scanIncludes(i *Info, chain cssselector.Chain) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	for _, doc := range i.Docs {
+		found := make([]bool, len(chain))
+		pending := len(chain)
+		for _, id := range doc {
+			for ci := range chain {
+				if !found[ci] && chain[ci].Matches(&i.Nodes[id]) {
+					found[ci] = true
+					pending--
+				}
+			}
+		}
+		if pending == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func set(values ...string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+var tags = []string{"a", "div", "span", "p", "li", "ul", "img", "input", "button", "section"}
+
+// randomNode returns a node drawn from a skewed distribution, so some tokens
+// are common and others are rare like they are in real markup.
+func randomNode(r *rand.Rand, distinct int) cssselector.Selector {
+	n := cssselector.Selector{Tag: tags[r.Intn(len(tags))]}
+	if r.Intn(4) == 0 {
+		n.ID = fmt.Sprintf("id%d", r.Intn(distinct*4))
+	}
+	var classes []string
+	for c := r.Intn(4); c > 0; c-- {
+		classes = append(classes, fmt.Sprintf("c%d", int(r.ExpFloat64()*float64(distinct/8))%distinct))
+	}
+	n.Class = set(classes...)
+	if r.Intn(3) == 0 {
+		n.Attr = set(fmt.Sprintf("data-a%d", r.Intn(distinct/10+1)))
+	}
+	return n
+}
+
+func randomCorpus(seed int64, docs, nodes, distinct int) *Info {
+	r := rand.New(rand.NewSource(seed))
+	var i Info
+	for d := 0; d < docs; d++ {
+		doc := make([]cssselector.Selector, nodes)
+		for n := range doc {
+			doc[n] = randomNode(r, distinct)
+		}
+		i.Add(doc)
+	}
+	return &i
+}
+
+func randomChains(seed int64, count, distinct int) []cssselector.Chain {
+	r := rand.New(rand.NewSource(seed))
+	chains := make([]cssselector.Chain, count)
+	for ci := range chains {
+		chain := make(cssselector.Chain, r.Intn(3)+1)
+		for si := range chain {
+			n := randomNode(r, distinct)
+			// selectors are usually less specific than the nodes they match
+			switch r.Intn(5) {
+			case 0:
+				n = cssselector.Selector{}
+			case 1:
+				n.Class, n.Attr, n.ID = nil, nil, ""
+			case 2:
+				n.Tag, n.ID = "", ""
+			}
+			chain[si] = n
+		}
+		chains[ci] = chain
+	}
+	return chains
+}
+
+func TestIndexMatchesScan(t *testing.T) {
+	info := randomCorpus(1, 200, 30, 400)
+	for i, chain := range randomChains(2, 2000, 400) {
+		ensure.DeepEqual(t, info.Includes(chain), scanIncludes(info, chain),
+			"chain", i, chain)
+	}
+}
+
+func TestIndexWithWordsAndPatternsMatchesScan(t *testing.T) {
+	info := randomCorpus(1, 200, 30, 400)
+	r := rand.New(rand.NewSource(3))
+	for doc := range info.Docs {
+		if r.Intn(2) == 0 {
+			continue
+		}
+		words := make(map[string]struct{})
+		for n := r.Intn(10); n > 0; n-- {
+			node := randomNode(r, 400)
+			for c := range node.Class {
+				words[c] = struct{}{}
+			}
+			if r.Intn(5) == 0 {
+				words[node.Tag] = struct{}{}
+			}
+		}
+		info.setWords(doc, words)
+		if r.Intn(4) == 0 {
+			p := wordusage.Pattern{Prefix: fmt.Sprintf("c%d", r.Intn(40))}
+			if r.Intn(2) == 0 {
+				p.Suffix = fmt.Sprint(r.Intn(10))
+			}
+			info.AddPatterns(doc, map[wordusage.Pattern]struct{}{p: {}})
+		}
+	}
+	for i, chain := range randomChains(2, 2000, 400) {
+		scan := false
+		for doc := range info.Docs {
+			if info.docIncludes(doc, chain) {
+				scan = true
+				break
+			}
+		}
+		ensure.DeepEqual(t, info.Includes(chain), scan, "chain", i, chain)
+	}
+}
+
+func TestIndexAfterMerge(t *testing.T) {
+	var info Info
+	info.Merge(infoOf(seen(t, "a")))
+	ensure.False(t, info.Includes(cssselector.Chain(seen(t, "b"))))
+	info.Merge(infoOf(seen(t, "b")))
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "b"))))
+}
+
+func TestIndexEmptyDocument(t *testing.T) {
+	info := infoOf(nil)
+	ensure.False(t, info.Includes(cssselector.Chain{{}}))
+}
+
+var (
+	benchOnce   sync.Once
+	benchInfo   *Info
+	benchChains []cssselector.Chain
+
+	benchLargeOnce sync.Once
+	benchLargeInfo *Info
+)
+
+// benchCorpus resembles a large static site: many pages each with a modest
+// number of nodes, and a stylesheet with a few thousand selectors.
+func benchCorpus() (*Info, []cssselector.Chain) {
+	benchOnce.Do(func() {
+		benchInfo = randomCorpus(1, 40000, 50, 5000)
+		benchChains = randomChains(2, 500, 5000)
+	})
+	return benchInfo, benchChains
+}
+
+// benchLargeCorpus resembles a few large pages, where the cost of checking
+// each candidate document dominates.
+func benchLargeCorpus() (*Info, []cssselector.Chain) {
+	_, chains := benchCorpus()
+	benchLargeOnce.Do(func() {
+		benchLargeInfo = randomCorpus(1, 10, 20000, 5000)
+	})
+	return benchLargeInfo, chains
+}
+
+func BenchmarkIncludesIndex(b *testing.B) {
+	info, chains := benchCorpus()
+	info.Includes(chains[0]) // build the index outside the timed loop
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, chain := range chains {
+			info.Includes(chain)
+		}
+	}
+}
+
+func BenchmarkIncludesScan(b *testing.B) {
+	info, chains := benchCorpus()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, chain := range chains {
+			scanIncludes(info, chain)
+		}
+	}
+}
+
+func BenchmarkIncludesIndexLargePages(b *testing.B) {
+	info, chains := benchLargeCorpus()
+	info.Includes(chains[0]) // build the index outside the timed loop
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, chain := range chains {
+			info.Includes(chain)
+		}
+	}
+}
+
+func BenchmarkIncludesScanLargePages(b *testing.B) {
+	info, chains := benchLargeCorpus()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, chain := range chains {
+			scanIncludes(info, chain)
+		}
+	}
+}
+
+func BenchmarkIndexBuild(b *testing.B) {
+	info, _ := benchCorpus()
+	for n := 0; n < b.N; n++ {
+		var x index
+		x.update(info)
+	}
+}
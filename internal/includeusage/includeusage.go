@@ -4,6 +4,7 @@ import (
 	"regexp"
 
 	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/usage"
 )
 
 type IncludeClass struct {
@@ -41,3 +42,54 @@ outer:
 	}
 	return true
 }
+
+type IncludeTag struct {
+	Re []*regexp.Regexp
+}
+
+func (i *IncludeTag) Includes(chain cssselector.Chain) bool {
+outer:
+	for _, s := range chain {
+		for _, r := range i.Re {
+			if s.Tag != "" && r.MatchString(s.Tag) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type IncludeAttr struct {
+	Re []*regexp.Regexp
+}
+
+func (i *IncludeAttr) Includes(chain cssselector.Chain) bool {
+outer:
+	for _, s := range chain {
+		for _, r := range i.Re {
+			for a := range s.Attr {
+				if r.MatchString(a) {
+					continue outer
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Greedy includes a chain if any of its parts alone is included by Info,
+// rather than requiring every part to be.
+type Greedy struct {
+	Info usage.Info
+}
+
+func (i *Greedy) Includes(chain cssselector.Chain) bool {
+	for n := range chain {
+		if i.Info.Includes(chain[n : n+1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -150,3 +150,38 @@ func TestNotIncludeID(t *testing.T) {
 		})
 	}
 }
+
+func TestIncludeTagAndAttr(t *testing.T) {
+	tag := IncludeTag{Re: []*regexp.Regexp{regexp.MustCompile("^(dialog|details)$")}}
+	attr := IncludeAttr{Re: []*regexp.Regexp{regexp.MustCompile("^data-tooltip$")}}
+	cases := []struct {
+		i        interface{ Includes(cssselector.Chain) bool }
+		s        string
+		included bool
+	}{
+		{i: &tag, s: "dialog.open", included: true},
+		{i: &tag, s: "details > summary"},
+		{i: &tag, s: ".dialog"},
+		{i: &attr, s: ".tip[data-tooltip]::after", included: true},
+		{i: &attr, s: "[data-tooltip-x]"},
+		{i: &attr, s: "body [data-tooltip]"},
+	}
+	for _, c := range cases {
+		s, err := cssselector.Parse(strings.NewReader(c.s))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, c.i.Includes(s), c.included, c.s)
+	}
+}
+
+func TestGreedy(t *testing.T) {
+	i := Greedy{Info: &IncludeClass{Re: []*regexp.Regexp{regexp.MustCompile("^modal")}}}
+	for s, included := range map[string]bool{
+		".modal .btn-close": true,
+		"body.modal-open a": true,
+		".dialog .close":    false,
+	} {
+		chain, err := cssselector.Parse(strings.NewReader(s))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, i.Includes(chain), included, s)
+	}
+}
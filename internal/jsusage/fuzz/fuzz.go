@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/jsusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = jsusage.Extract(bytes.NewReader(b), nil, true)
+	return 0
+}
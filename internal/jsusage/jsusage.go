@@ -0,0 +1,195 @@
+// Package jsusage extracts the words in JavaScript and TypeScript files. Unlike
+// the word extractor, only the words in string and template literals are seen,
+// and optionally those in comments, so identifiers and keywords like error or
+// button do not keep the rules using them. The static pieces of template
+// literals are seen, but not their substitutions. Classes built dynamically,
+// like 'btn-' + variant or `text-${color}-500`, are matched by the patterns
+// inferred from them.
+package jsusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/wordusage"
+
+	"github.com/pkg/errors"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// isValue returns true if the token may be followed by a division rather than
+// a regular expression.
+func isValue(tt js.TokenType) bool {
+	switch tt {
+	case js.StringToken, js.TemplateToken, js.TemplateEndToken, js.RegExpToken,
+		js.CloseParenToken, js.CloseBracketToken, js.CloseBraceToken:
+		return true
+	}
+	return js.IsNumeric(tt) || js.IsIdentifier(tt)
+}
+
+// separators end the prefixes and begin the suffixes of classes built by
+// concatenation or interpolation, like 'btn-' + variant.
+const separators = "-_:"
+
+// Prefix returns the last word of the literal if it ends with a separator, and
+// so is the prefix of the classes built by appending to it.
+func Prefix(literal string) string {
+	word := literal[strings.LastIndexAny(literal, " \t\r\n")+1:]
+	if word == "" || !strings.ContainsAny(word[len(word)-1:], separators) ||
+		strings.Trim(word, separators) == "" {
+		return ""
+	}
+	return strings.ToLower(word)
+}
+
+// Suffix returns the first word of the literal if it begins with a separator,
+// and so is the suffix of the classes built by prepending to it.
+func Suffix(literal string) string {
+	word := literal
+	if i := strings.IndexAny(literal, " \t\r\n"); i != -1 {
+		word = literal[:i]
+	}
+	if word == "" || !strings.ContainsAny(word[:1], separators) ||
+		strings.Trim(word, separators) == "" {
+		return ""
+	}
+	return strings.ToLower(word)
+}
+
+// whole returns true if the literal is a single word.
+func whole(literal string) bool {
+	return !strings.ContainsAny(literal, " \t\r\n")
+}
+
+type scanner struct {
+	literals bytes.Buffer
+	patterns map[wordusage.Pattern]struct{}
+	// holes is the stack of the prefixes before the substitutions in the
+	// template literals being scanned.
+	holes []string
+}
+
+func (s *scanner) add(p wordusage.Pattern) {
+	if p.Prefix == "" && p.Suffix == "" {
+		return
+	}
+	if s.patterns == nil {
+		s.patterns = make(map[wordusage.Pattern]struct{})
+	}
+	s.patterns[p] = struct{}{}
+}
+
+// open records the prefix before a substitution. The last word of a chunk
+// following another substitution is not a prefix, since it does not begin
+// with the chunk.
+func (s *scanner) open(chunk string, afterHole bool) {
+	if afterHole && whole(chunk) {
+		s.holes = append(s.holes, "")
+	} else {
+		s.holes = append(s.holes, Prefix(chunk))
+	}
+}
+
+// close records the pattern of a substitution, with the suffix after it. The
+// first word of a chunk preceding another substitution is not a suffix, since
+// it does not end the chunk.
+func (s *scanner) close(chunk string, beforeHole bool) {
+	if len(s.holes) == 0 {
+		return
+	}
+	p := wordusage.Pattern{Prefix: s.holes[len(s.holes)-1]}
+	s.holes = s.holes[:len(s.holes)-1]
+	if !beforeHole || !whole(chunk) {
+		p.Suffix = Suffix(chunk)
+	}
+	s.add(p)
+}
+
+// scan collects the contents of the string and template literals in the
+// script, one per line, along with the comments if requested, and the
+// patterns of the classes built from them.
+func (s *scanner) scan(script []byte, comments bool) error {
+	l := js.NewLexer(parse.NewInputBytes(script))
+	value := false
+	prev := js.ErrorToken
+	pending := "" // the prefix in the last string literal
+	for {
+		tt, data := l.Next()
+		switch tt {
+		case js.ErrorToken:
+			if err := l.Err(); err != io.EOF {
+				return errors.WithStack(err)
+			}
+			return nil
+		case js.WhitespaceToken, js.LineTerminatorToken:
+			continue
+		case js.CommentToken, js.CommentLineTerminatorToken:
+			if comments {
+				s.literals.Write(data)
+				s.literals.WriteByte('\n')
+			}
+			continue
+		}
+		if tt == js.AddToken && pending != "" {
+			s.add(wordusage.Pattern{Prefix: pending})
+		}
+		pending = ""
+		switch tt {
+		case js.StringToken:
+			literal := string(data[1 : len(data)-1])
+			if prev == js.AddToken || prev == js.AddEqToken {
+				s.add(wordusage.Pattern{Suffix: Suffix(literal)})
+			}
+			pending = Prefix(literal)
+		case js.TemplateStartToken:
+			s.open(string(data[1:len(data)-2]), false)
+		case js.TemplateMiddleToken:
+			chunk := string(data[1 : len(data)-2])
+			s.close(chunk, true)
+			s.open(chunk, true)
+		case js.TemplateEndToken:
+			s.close(string(data[1:len(data)-1]), false)
+		}
+		switch tt {
+		case js.StringToken, js.TemplateToken, js.TemplateStartToken, js.TemplateMiddleToken, js.TemplateEndToken:
+			s.literals.Write(data)
+			s.literals.WriteByte('\n')
+		case js.DivToken, js.DivEqToken:
+			if !value {
+				tt, _ = l.RegExp()
+			}
+		}
+		value = isValue(tt)
+		prev = tt
+	}
+}
+
+// Extract returns the words in the string and template literals of the script
+// using the tokenizer, or the wordusage.DefaultTokenizer if it is nil. The
+// words in comments are included if requested. Literals ending with a separator
+// like 'btn-' which are concatenated or interpolated into, or beginning with one
+// like '-active' which are concatenated to, are the prefixes and suffixes of
+// the patterns of classes built dynamically.
+func Extract(r io.Reader, t *wordusage.Tokenizer, comments bool) (*wordusage.Info, error) {
+	script, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var s scanner
+	if err := s.scan(script, comments); err != nil {
+		return nil, err
+	}
+	if t == nil {
+		t = wordusage.DefaultTokenizer
+	}
+	info, err := t.Extract(&s.literals)
+	if err != nil {
+		return nil, err
+	}
+	info.Patterns = s.patterns
+	return info, nil
+}
@@ -0,0 +1,133 @@
+package jsusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/wordusage"
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name      string
+		in        string
+		tokenizer *wordusage.Tokenizer
+		comments  bool
+		seen      map[string]struct{}
+	}{
+		{
+			name: "strings",
+			in:   `const error = "alert-error"; button.classList.add('btn', "btn-primary")`,
+			seen: set("alert-error", "btn", "btn-primary"),
+		},
+		{
+			name: "templates",
+			in:   "el.className = `card ${open ? 'open' : `closed`} card-${size}`",
+			seen: set("card", "open", "closed", "card-"),
+		},
+		{
+			name: "regexps and divisions",
+			in:   "const re = /'not-a-string'/g; const half = width / 2 / 'x'.length; f('a')",
+			seen: set("x", "a"),
+		},
+		{
+			name: "comments excluded",
+			in:   "// uses .comment-class\n/* and\nmore */ f('used')",
+			seen: set("used"),
+		},
+		{
+			name:     "comments included",
+			in:       "// uses .comment-class\n/* and\nmore */ f('used')",
+			comments: true,
+			seen:     set("uses", "comment-class", "and", "more", "used"),
+		},
+		{
+			name:      "tokenizer",
+			in:        `cn("md:flex", 'w-1/2')`,
+			tokenizer: wordusage.TailwindTokenizer,
+			seen:      set("md:flex", "md", "flex", "w-1/2", "w-1", "2"),
+		},
+		{
+			name: "typescript",
+			in:   "const size: Size = 'large' as Size; function f<T>(x: T): T { return x }",
+			seen: set("large"),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.in), c.tokenizer, c.comments)
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, info.Seen, c.seen)
+		})
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	cases := []struct {
+		name     string
+		in       string
+		patterns []wordusage.Pattern
+	}{
+		{
+			name: "concatenation",
+			in:   `el.className = 'btn btn-' + variant + ' ' + size + '-size'; cls += '_active'`,
+			patterns: []wordusage.Pattern{
+				{Suffix: "-size"},
+				{Suffix: "_active"},
+				{Prefix: "btn-"},
+			},
+		},
+		{
+			name: "interpolation",
+			in:   "`Text-${color}-500 bg-${color} ${a}-${b}-c ${x}y md:${d}`",
+			patterns: []wordusage.Pattern{
+				{Suffix: "-c"},
+				{Prefix: "bg-"},
+				{Prefix: "md:"},
+				{Prefix: "text-", Suffix: "-500"},
+			},
+		},
+		{
+			name: "nested",
+			in:   "`card ${open ? `card-${size}` : 'closed'}`",
+			patterns: []wordusage.Pattern{
+				{Prefix: "card-"},
+			},
+		},
+		{
+			name:     "no separators",
+			in:       "'btn' + variant + 'x'; `${a}-${b}`; '-' + c",
+			patterns: []wordusage.Pattern{},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.in), nil, false)
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, info.SortedPatterns(), c.patterns)
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-jsusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f, nil, false)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
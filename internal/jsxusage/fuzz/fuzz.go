@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/jsxusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = jsxusage.Extract(bytes.NewReader(b), jsxusage.DefaultHelpers)
+	return 0
+}
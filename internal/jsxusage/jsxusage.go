@@ -0,0 +1,517 @@
+// Package jsxusage extracts usage information from JSX and TSX files. Elements
+// are read as nodes, with their classes collected from the string literals,
+// template literals and object keys in className and class expressions, and
+// in calls to helpers like clsx. Classes built dynamically, like 'btn-' +
+// variant or `text-${color}-500`, are matched by the patterns inferred from
+// them as they are by the jsusage package.
+package jsxusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+	"github.com/daaku/cssdalek/internal/jsusage"
+	"github.com/daaku/cssdalek/internal/wordusage"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHelpers are the common functions whose arguments are classes.
+var DefaultHelpers = []string{"clsx", "cn", "classnames", "classNames", "cx"}
+
+var (
+	classNameB = []byte("classname")
+	classB     = []byte("class")
+	idB        = []byte("id")
+)
+
+// keywords after which an expression, and so a regexp or element, may follow.
+var keywords = map[string]bool{
+	"await":      true,
+	"case":       true,
+	"default":    true,
+	"delete":     true,
+	"do":         true,
+	"else":       true,
+	"in":         true,
+	"instanceof": true,
+	"new":        true,
+	"of":         true,
+	"return":     true,
+	"throw":      true,
+	"typeof":     true,
+	"void":       true,
+	"yield":      true,
+}
+
+type scanner struct {
+	data    []byte
+	pos     int
+	helpers map[string]bool
+	nodes   []cssselector.Selector
+	words   map[string]struct{}
+	// patterns are those of the classes built dynamically anywhere, since
+	// they are often built away from the elements using them.
+	patterns map[wordusage.Pattern]struct{}
+}
+
+func (s *scanner) addPattern(p wordusage.Pattern) {
+	if p.Prefix == "" && p.Suffix == "" {
+		return
+	}
+	if s.patterns == nil {
+		s.patterns = make(map[wordusage.Pattern]struct{})
+	}
+	s.patterns[p] = struct{}{}
+}
+
+func isIdentByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '_' || b == '$' || b >= 0x80
+}
+
+func isNameByte(b byte) bool {
+	return isIdentByte(b) || b == '-' || b == ':' || b == '.'
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func (s *scanner) peek(offset int) byte {
+	if s.pos+offset < len(s.data) {
+		return s.data[s.pos+offset]
+	}
+	return 0
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) && isSpace(s.data[s.pos]) {
+		s.pos++
+	}
+}
+
+// whole returns true if the template literal part is a single word.
+func whole(part []byte) bool {
+	return bytes.IndexAny(part, " \t\r\n") == -1
+}
+
+// nextSignificant returns the next byte which isn't whitespace.
+func (s *scanner) nextSignificant() byte {
+	for i := s.pos; i < len(s.data); i++ {
+		if !isSpace(s.data[i]) {
+			return s.data[i]
+		}
+	}
+	return 0
+}
+
+// addFields adds the whitespace separated fields to the set.
+func addFields(set map[string]struct{}, data []byte) {
+	for _, f := range bytes.Fields(data) {
+		set[string(bytes.ToLower(f))] = struct{}{}
+	}
+}
+
+// js scans code until the unmatched end byte. The string literals, template
+// literal parts and object keys are added to classes if it isn't nil.
+func (s *scanner) js(end byte, classes map[string]struct{}) {
+	var stack []byte
+	var prev byte      // the last significant byte, with 'a' for values
+	var pending string // the prefix in the last string literal
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		prefix := ""
+		switch {
+		case isSpace(c):
+			s.pos++
+			continue
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, c)
+			s.pos++
+		case c == ')' || c == ']' || c == '}':
+			s.pos++
+			if len(stack) == 0 {
+				if c == end {
+					return
+				}
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case c == '"' || c == '\'':
+			value := string(s.quoted())
+			if classes != nil {
+				addFields(classes, []byte(value))
+			}
+			if prev == '+' {
+				s.addPattern(wordusage.Pattern{Suffix: jsusage.Suffix(value)})
+			}
+			prefix = jsusage.Prefix(value)
+			c = 'a'
+		case c == '+':
+			if pending != "" {
+				s.addPattern(wordusage.Pattern{Prefix: pending})
+			}
+			s.pos++
+			if s.peek(0) == '=' {
+				s.pos++
+			}
+		case c == '`':
+			s.template(classes)
+			c = 'a'
+		case c == '/' && s.peek(1) == '/':
+			for s.pos < len(s.data) && s.data[s.pos] != '\n' {
+				s.pos++
+			}
+			continue
+		case c == '/' && s.peek(1) == '*':
+			if i := bytes.Index(s.data[s.pos+2:], []byte("*/")); i != -1 {
+				s.pos += i + 4
+			} else {
+				s.pos = len(s.data)
+			}
+			continue
+		case c == '/' && prev != 'a' && prev != ')' && prev != ']':
+			s.regexp()
+			c = 'a'
+		case c == '<' && prev != 'a' && prev != ')' && prev != ']' &&
+			(isLetter(s.peek(1)) || s.peek(1) == '>'):
+			s.element()
+			c = 'a'
+		case isIdentByte(c):
+			start := s.pos
+			for s.pos < len(s.data) && isIdentByte(s.data[s.pos]) {
+				s.pos++
+			}
+			ident := string(s.data[start:s.pos])
+			next := s.nextSignificant()
+			inObject := len(stack) > 0 && stack[len(stack)-1] == '{'
+			switch {
+			case s.helpers[ident] && next == '(':
+				s.skipSpace()
+				s.pos++
+				target := classes
+				if target == nil {
+					target = s.words
+				}
+				s.js(')', target)
+			case classes != nil && inObject && (prev == '{' || prev == ',') &&
+				(next == ':' || next == ',' || next == '}'):
+				classes[strings.ToLower(ident)] = struct{}{}
+			}
+			c = 'a'
+			if keywords[ident] {
+				c = '='
+			}
+		default:
+			s.pos++
+		}
+		prev = c
+		pending = prefix
+	}
+}
+
+// quoted returns the contents of the string literal at the current position.
+func (s *scanner) quoted() []byte {
+	quote := s.data[s.pos]
+	s.pos++
+	start := s.pos
+	for s.pos < len(s.data) && s.data[s.pos] != quote && s.data[s.pos] != '\n' {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+		s.pos++
+	}
+	if s.pos > len(s.data) {
+		s.pos = len(s.data)
+	}
+	value := s.data[start:s.pos]
+	if s.pos < len(s.data) {
+		s.pos++
+	}
+	return value
+}
+
+// template scans the template literal at the current position. Parts which
+// touch a substitution are incomplete, and aren't added to classes, but they
+// may be the prefix and suffix of a pattern.
+func (s *scanner) template(classes map[string]struct{}) {
+	s.pos++
+	start := s.pos
+	afterHole := false
+	hole := "" // the prefix before the last substitution
+	add := func(part []byte, beforeHole bool) {
+		// like in the jsusage package, a word between two substitutions is
+		// neither a prefix nor a suffix
+		if afterHole {
+			p := wordusage.Pattern{Prefix: hole}
+			if !beforeHole || !whole(part) {
+				p.Suffix = jsusage.Suffix(string(part))
+			}
+			s.addPattern(p)
+		}
+		if beforeHole {
+			hole = ""
+			if !afterHole || !whole(part) {
+				hole = jsusage.Prefix(string(part))
+			}
+		}
+		if classes == nil {
+			return
+		}
+		fields := bytes.Fields(part)
+		if afterHole && len(part) > 0 && !isSpace(part[0]) && len(fields) > 0 {
+			fields = fields[1:]
+		}
+		if beforeHole && len(part) > 0 && !isSpace(part[len(part)-1]) && len(fields) > 0 {
+			fields = fields[:len(fields)-1]
+		}
+		for _, f := range fields {
+			classes[string(bytes.ToLower(f))] = struct{}{}
+		}
+	}
+	for s.pos < len(s.data) {
+		switch {
+		case s.data[s.pos] == '\\':
+			s.pos += 2
+		case s.data[s.pos] == '`':
+			add(s.data[start:s.pos], false)
+			s.pos++
+			return
+		case s.data[s.pos] == '$' && s.peek(1) == '{':
+			add(s.data[start:s.pos], true)
+			s.pos += 2
+			s.js('}', classes)
+			start = s.pos
+			afterHole = true
+		default:
+			s.pos++
+		}
+	}
+	if start < len(s.data) {
+		add(s.data[start:], false)
+	}
+}
+
+// regexp skips the regular expression literal at the current position.
+func (s *scanner) regexp() {
+	s.pos++
+	inClass := false
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return
+		case '/':
+			if !inClass {
+				s.pos++
+				for s.pos < len(s.data) && isIdentByte(s.data[s.pos]) {
+					s.pos++
+				}
+				return
+			}
+		}
+		s.pos++
+	}
+}
+
+// element scans the element at the current position, including its children.
+// Components, which start with an uppercase letter or contain a dot, are
+// nodes without a tag since they may render any element.
+func (s *scanner) element() {
+	s.pos++
+	start := s.pos
+	for s.pos < len(s.data) && isNameByte(s.data[s.pos]) {
+		s.pos++
+	}
+	name := s.data[start:s.pos]
+	var node cssselector.Selector
+	if len(name) > 0 && name[0] >= 'a' && name[0] <= 'z' && bytes.IndexByte(name, '.') == -1 {
+		node.Tag = string(bytes.ToLower(name))
+	}
+	var ids []string
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return
+		}
+		switch c := s.data[s.pos]; {
+		case c == '/' && s.peek(1) == '>':
+			s.pos += 2
+			s.emit(name, &node, ids)
+			return
+		case c == '>':
+			s.pos++
+			s.emit(name, &node, ids)
+			s.children()
+			return
+		case c == '{':
+			s.pos++
+			s.js('}', nil)
+		case isNameByte(c):
+			ids = s.attr(&node, ids)
+		default:
+			// not an element after all, such as a TypeScript type parameter
+			return
+		}
+	}
+}
+
+// attr scans the attribute at the current position into the node.
+func (s *scanner) attr(node *cssselector.Selector, ids []string) []string {
+	start := s.pos
+	for s.pos < len(s.data) && isNameByte(s.data[s.pos]) {
+		s.pos++
+	}
+	name := bytes.ToLower(s.data[start:s.pos])
+	isClass := bytes.Equal(name, classNameB) || bytes.Equal(name, classB)
+	isID := bytes.Equal(name, idB)
+	if !isClass && !isID {
+		if node.Attr == nil {
+			node.Attr = make(map[string]struct{})
+		}
+		node.Attr[string(name)] = struct{}{}
+	}
+
+	s.skipSpace()
+	if s.peek(0) != '=' {
+		return ids
+	}
+	s.pos++
+	s.skipSpace()
+	values := make(map[string]struct{})
+	switch s.peek(0) {
+	case '"', '\'':
+		quote := s.data[s.pos]
+		s.pos++
+		start := s.pos
+		for s.pos < len(s.data) && s.data[s.pos] != quote {
+			s.pos++
+		}
+		addFields(values, s.data[start:s.pos])
+		if s.pos < len(s.data) {
+			s.pos++
+		}
+	case '{':
+		s.pos++
+		if isClass || isID {
+			s.js('}', values)
+		} else {
+			s.js('}', nil)
+		}
+	case '<':
+		s.element()
+	}
+	if isClass {
+		if node.Class == nil {
+			node.Class = make(map[string]struct{})
+		}
+		for v := range values {
+			node.Class[v] = struct{}{}
+		}
+	}
+	if isID {
+		for v := range values {
+			ids = append(ids, v)
+		}
+		sort.Strings(ids)
+	}
+	return ids
+}
+
+// emit adds the node, once for each of the IDs it may have.
+func (s *scanner) emit(name []byte, node *cssselector.Selector, ids []string) {
+	if len(name) == 0 {
+		return
+	}
+	if len(ids) == 0 {
+		s.nodes = append(s.nodes, *node)
+		return
+	}
+	for _, id := range ids {
+		n := *node
+		n.ID = id
+		s.nodes = append(s.nodes, n)
+	}
+}
+
+// children scans the children of an element, up to and including its closing
+// tag.
+func (s *scanner) children() {
+	for s.pos < len(s.data) {
+		switch c := s.data[s.pos]; {
+		case c == '{':
+			s.pos++
+			s.js('}', nil)
+		case c == '<' && s.peek(1) == '/':
+			if i := bytes.IndexByte(s.data[s.pos:], '>'); i != -1 {
+				s.pos += i + 1
+			} else {
+				s.pos = len(s.data)
+			}
+			return
+		case c == '<' && (isLetter(s.peek(1)) || s.peek(1) == '>'):
+			s.element()
+		default:
+			s.pos++
+		}
+	}
+}
+
+func helperSet(helpers []string) map[string]bool {
+	set := make(map[string]bool, len(helpers))
+	for _, h := range helpers {
+		set[h] = true
+	}
+	return set
+}
+
+// ClassExpr returns the classes in a JavaScript class expression, such as the
+// string literals, template literal parts and object keys in
+// ['a', {b: c}, `d ${e}`].
+func ClassExpr(expr []byte, helpers []string) map[string]struct{} {
+	s := scanner{
+		data:    expr,
+		helpers: helperSet(helpers),
+		words:   make(map[string]struct{}),
+	}
+	classes := make(map[string]struct{})
+	s.js(0, classes)
+	return classes
+}
+
+// Extract returns the usage information for a JSX or TSX file. The arguments
+// to the helpers are considered seen in the document, even outside of
+// elements, as are the classes matching the patterns inferred anywhere.
+func Extract(r io.Reader, helpers []string) (*htmlusage.Info, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s := scanner{
+		data:    data,
+		helpers: helperSet(helpers),
+		words:   make(map[string]struct{}),
+	}
+	s.js(0, nil)
+
+	info := new(htmlusage.Info)
+	info.Add(s.nodes)
+	info.AddWords(0, s.words)
+	info.AddPatterns(0, s.patterns)
+	return info, nil
+}
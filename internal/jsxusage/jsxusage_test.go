@@ -0,0 +1,175 @@
+package jsxusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/wordusage"
+
+	"github.com/daaku/ensure"
+)
+
+func seen(t testing.TB, selectors ...string) []cssselector.Selector {
+	var parsed []cssselector.Selector
+	for _, s := range selectors {
+		p, err := cssselector.Parse(strings.NewReader(s))
+		ensure.Nil(t, err, "for selector", s)
+		parsed = append(parsed, p...)
+	}
+	return parsed
+}
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		jsx  string
+		seen []cssselector.Selector
+	}{
+		{
+			name: "class name",
+			jsx:  `const a = <a className="foo Bar">x</a>`,
+			seen: seen(t, "a.foo.bar"),
+		},
+		{
+			name: "template literal",
+			jsx:  "const a = <div className={`card ${active ? 'active' : ''} size-${size}`} />",
+			seen: seen(t, "div.card.active"),
+		},
+		{
+			name: "helper call",
+			jsx:  `return <p className={clsx('x', {isOpen: cond, 'z-z': other}, [w && 'w'])}></p>`,
+			seen: seen(t, "p.x.isopen.z-z.w"),
+		},
+		{
+			name: "id and attrs",
+			jsx:  `f(<input id="name" type="text" disabled onChange={() => set(x)} />)`,
+			seen: []cssselector.Selector{
+				{Tag: "input", ID: "name", Attr: set("type", "disabled", "onchange")},
+			},
+		},
+		{
+			name: "id alternatives",
+			jsx:  `x = <a id={open ? "open" : "closed"} />`,
+			seen: seen(t, "a#closed", "a#open"),
+		},
+		{
+			name: "nested",
+			jsx: `
+export default function App() {
+  return (
+    <main className="app">
+      {items.map(item => <li key={item.id} className="item">{item.name}</li>)}
+      <>
+        <span class="frag" />
+      </>
+    </main>
+  )
+}`,
+			seen: []cssselector.Selector{
+				{Tag: "main", Class: set("app")},
+				{Tag: "li", Class: set("item"), Attr: set("key")},
+				{Tag: "span", Class: set("frag")},
+			},
+		},
+		{
+			name: "component",
+			jsx:  `x = <><Button className="btn" /> <UI.Card className="card" /></>`,
+			seen: seen(t, ".btn", ".card"),
+		},
+		{
+			name: "comparison is not an element",
+			jsx:  `if (a <b) { x = <i className="y" /> }`,
+			seen: seen(t, "i.y"),
+		},
+		{
+			name: "type parameters",
+			jsx:  `const f = <T,>(x: T) => <b className="y" />; useState<string>('a')`,
+			seen: seen(t, "b.y"),
+		},
+		{
+			name: "strings and comments",
+			jsx: `
+const s = "<a className='no'>" // <b className="no">
+/* <i className="no"> */
+const r = /<u className="no">/g
+const v = <em className="yes" />`,
+			seen: seen(t, "em.yes"),
+		},
+		{
+			name: "text with quotes",
+			jsx:  `<p>It's {"a"} <b className="x">don't</b></p>`,
+			seen: seen(t, "p", "b.x"),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.jsx), DefaultHelpers)
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+func TestExtractHelpers(t *testing.T) {
+	jsx := `const cls = cn('menu', {open}); const x = other('no'); <nav className={cls} />`
+	info, err := Extract(strings.NewReader(jsx), DefaultHelpers)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Words, []map[string]struct{}{set("menu", "open")})
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "nav.menu.open"))))
+	ensure.False(t, info.Includes(cssselector.Chain(seen(t, ".no"))))
+
+	info, err = Extract(strings.NewReader(jsx), []string{"other"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Words, []map[string]struct{}{set("no")})
+}
+
+func TestExtractPatterns(t *testing.T) {
+	jsx := "const cls = 'btn-' + variant; cls += '-lg';\n" +
+		"<button className={cls}><i className={`text-${c}-500 icon-${name}`} /></button>"
+	info, err := Extract(strings.NewReader(jsx), DefaultHelpers)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.SortedPatterns(), []wordusage.Pattern{
+		{Suffix: "-lg"},
+		{Prefix: "btn-"},
+		{Prefix: "icon-"},
+		{Prefix: "text-", Suffix: "-500"},
+	})
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "button.btn-primary"))))
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "i.text-red-500"))))
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, ".icon-close"))))
+	ensure.False(t, info.Includes(cssselector.Chain(seen(t, ".text-red-400"))))
+	ensure.False(t, info.Includes(cssselector.Chain(seen(t, "#btn-primary"))))
+	ensure.False(t, info.Includes(cssselector.Chain(seen(t, "a.btn-primary"))))
+}
+
+func TestClassExpr(t *testing.T) {
+	ensure.DeepEqual(t,
+		ClassExpr([]byte("['a', {b: c, 'd e': f}, `g ${h}`, cx('i')]"), DefaultHelpers),
+		set("a", "b", "d", "e", "g", "i"))
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-jsxusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f, nil)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
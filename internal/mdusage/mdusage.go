@@ -0,0 +1,1017 @@
+// Package mdusage extracts usage information from Markdown files. The document
+// is rewritten as the HTML elements the CommonMark and GitHub Flavored Markdown
+// constructs produce, without their text, and read by htmlusage. Raw HTML is
+// kept as it is, and attribute lists like {.class #id} add to the elements
+// they follow.
+package mdusage
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})(?:[ \t]+|$)`)
+	closingRe   = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	breakRe     = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	itemRe      = regexp.MustCompile(`^(?:([-*+])|(\d{1,9})([.)]))(?:[ \t]+|$)`)
+	taskRe      = regexp.MustCompile(`^\[([ xX])\](?:[ \t]|$)`)
+	fenceRe     = regexp.MustCompile("^(`{3,}|~{3,})[ \t]*(.*)$")
+	delimRe     = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?$`)
+	refDefRe    = regexp.MustCompile(`^[ \t]{0,3}\[([^\]]+)\]:[ \t]*\S`)
+	htmlBlockRe = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9-]*)(?:[ \t/>]|$)`)
+	schemeRe    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*$`)
+	emailRe     = regexp.MustCompile(`^[^\s<>@]+@[^\s<>@]+$`)
+	bareURLRe   = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]*[^\s<?!.,:*_~)]`)
+)
+
+// rawTags are the elements whose HTML blocks end at their closing tag rather
+// than at a blank line.
+var rawTags = map[string]bool{
+	"pre":      true,
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+}
+
+// blockTags are the elements which start an HTML block even within a
+// paragraph.
+var blockTags = map[string]bool{}
+
+func init() {
+	for _, t := range strings.Fields(`
+		address article aside base basefont blockquote body caption center col
+		colgroup dd details dialog dir div dl dt fieldset figcaption figure footer
+		form frame frameset h1 h2 h3 h4 h5 h6 head header hr html iframe legend li
+		link main menu menuitem nav noframes ol optgroup option p param search
+		section summary table tbody td tfoot th thead title tr track ul`) {
+		blockTags[t] = true
+	}
+}
+
+// container is an open blockquote or list item. The contents of a list item
+// are indented by indent columns within its own container.
+type container struct {
+	quote  bool
+	indent int
+	marker byte
+}
+
+type rewriter struct {
+	lines     []string
+	pos       int
+	out       []byte
+	refs      map[string]bool // link reference definitions
+	notes     map[string]bool // footnote definitions
+	open      []container
+	sibling   byte   // the marker of the item just closed, which the next may continue
+	bare      bool   // the paragraph is directly in a list item, so it has no <p>
+	para      bool   // in a paragraph
+	blank     bool   // the previous line was blank
+	code      bool   // in an indented code block
+	fence     string // the opening fence of the code block
+	htmlEnd   string // what ends the HTML block, with "" for a blank line
+	inHTML    bool
+	table     []string // the alignment of each column of the table
+	lastBlock int      // where attributes for the last block are inserted
+	footnotes bool
+	note      bool // the paragraph is a footnote, which may be followed by others
+}
+
+// attrs returns the HTML attributes for an attribute list like
+// {.class #id key=value}, or false if it isn't one.
+func attrs(list string) (string, bool) {
+	if len(list) < 2 || list[0] != '{' || list[len(list)-1] != '}' {
+		return "", false
+	}
+	body := strings.TrimPrefix(list[1:len(list)-1], ":")
+	var classes []string
+	var out strings.Builder
+	for len(body) > 0 {
+		body = strings.TrimLeft(body, " \t")
+		if body == "" {
+			break
+		}
+		end := strings.IndexAny(body, " \t")
+		if end == -1 {
+			end = len(body)
+		}
+		switch body[0] {
+		case '.':
+			classes = append(classes, body[1:end])
+			body = body[end:]
+			continue
+		case '#':
+			out.WriteString(` id="` + html.EscapeString(body[1:end]) + `"`)
+			body = body[end:]
+			continue
+		}
+		eq := strings.IndexByte(body, '=')
+		if eq == -1 || eq > end {
+			// plain words are more likely text in braces
+			return "", false
+		}
+		name := body[:eq]
+		if !isName(name) {
+			return "", false
+		}
+		body = body[eq+1:]
+		var value string
+		if len(body) > 0 && (body[0] == '"' || body[0] == '\'') {
+			end := strings.IndexByte(body[1:], body[0])
+			if end == -1 {
+				return "", false
+			}
+			value, body = body[1:end+1], body[end+2:]
+		} else {
+			end := strings.IndexAny(body, " \t")
+			if end == -1 {
+				end = len(body)
+			}
+			value, body = body[:end], body[end:]
+		}
+		if name == "class" {
+			classes = append(classes, strings.Fields(value)...)
+			continue
+		}
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if len(classes) > 0 {
+		return ` class="` + html.EscapeString(strings.Join(classes, " ")) + `"` + out.String(), true
+	}
+	return out.String(), true
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// trailingAttrs splits an attribute list off the end of the text.
+func trailingAttrs(text string) (string, string) {
+	text = strings.TrimRight(text, " \t")
+	if !strings.HasSuffix(text, "}") {
+		return text, ""
+	}
+	start := strings.LastIndexByte(text, '{')
+	if start == -1 {
+		return text, ""
+	}
+	a, ok := attrs(text[start:])
+	if !ok {
+		return text, ""
+	}
+	return strings.TrimRight(text[:start], " \t"), a
+}
+
+// indent returns the width of the leading whitespace, with tabs to the next
+// multiple of 4, and the line with it removed.
+func indent(line string) (int, string) {
+	width := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width, line[i:]
+		}
+	}
+	return width, ""
+}
+
+// refKey normalizes a link label.
+func refKey(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// frontMatter returns the document without its YAML or TOML front matter.
+func frontMatter(doc string) string {
+	for _, delim := range []string{"---", "+++"} {
+		if !strings.HasPrefix(doc, delim+"\n") && !strings.HasPrefix(doc, delim+"\r\n") {
+			continue
+		}
+		lines := strings.SplitAfter(doc, "\n")
+		offset := len(lines[0])
+		for _, line := range lines[1:] {
+			offset += len(line)
+			trimmed := strings.TrimRight(line, " \t\r\n")
+			if trimmed == delim || (delim == "---" && trimmed == "...") {
+				return doc[offset:]
+			}
+		}
+	}
+	return doc
+}
+
+func (r *rewriter) emit(tags ...string) {
+	for _, t := range tags {
+		r.out = append(r.out, t...)
+	}
+}
+
+// block emits the opening tag of a block, which attribute lists on the
+// following line add to.
+func (r *rewriter) block(tag, attrs string) {
+	r.out = append(r.out, '<')
+	r.out = append(r.out, tag...)
+	r.out = append(r.out, attrs...)
+	r.lastBlock = len(r.out)
+	r.out = append(r.out, '>')
+}
+
+// addAttrs inserts the attributes into the tag of the last block.
+func (r *rewriter) addAttrs(a string) {
+	r.out = append(r.out[:r.lastBlock], append([]byte(a), r.out[r.lastBlock:]...)...)
+	r.lastBlock += len(a)
+}
+
+// closeBlocks ends the leaf blocks.
+func (r *rewriter) closeBlocks() {
+	r.para = false
+	r.note = false
+	r.code = false
+	r.table = nil
+}
+
+// line rewrites a line of the document. The open containers continued by the
+// line are matched first, and those which aren't are closed, unless the line
+// lazily continues a paragraph. Then any new containers are opened, and the
+// rest of the line is their content.
+func (r *rewriter) line(line string) {
+	rest := strings.TrimRight(line, "\r")
+
+	matched := 0
+	for ; matched < len(r.open); matched++ {
+		c := r.open[matched]
+		n, s := indent(rest)
+		if c.quote {
+			if n > 3 || !strings.HasPrefix(s, ">") {
+				break
+			}
+			rest = strings.TrimPrefix(s[1:], " ")
+		} else if s != "" {
+			if n < c.indent {
+				break
+			}
+			rest = dedent(rest, c.indent)
+		}
+	}
+	blank := strings.TrimSpace(rest) == ""
+
+	if r.fence != "" || r.inHTML {
+		if matched == len(r.open) {
+			r.leaf(rest, blank)
+			return
+		}
+		// the container of the code or HTML block ended
+		r.fence = ""
+		r.inHTML = false
+	}
+
+	if matched < len(r.open) && r.para && !blank && !opens(rest) && continues(rest) {
+		r.paraLine(strings.TrimLeft(rest, " \t"))
+		r.blank = false
+		return
+	}
+	// a blank line within a list item makes its list loose
+	loose := r.blank && !blank && matched > 0 && !r.open[matched-1].quote
+	r.close(matched)
+
+	opened := false
+	for {
+		n, s := indent(rest)
+		if n >= 4 || breakRe.MatchString(strings.TrimRight(s, " \t")) {
+			break
+		}
+		if strings.HasPrefix(s, ">") {
+			r.closeBlocks()
+			r.block("blockquote", "")
+			r.open = append(r.open, container{quote: true})
+			r.sibling = 0
+			rest = strings.TrimPrefix(s[1:], " ")
+			opened = true
+			continue
+		}
+		m := itemRe.FindStringSubmatchIndex(s)
+		if m == nil || (r.para && !interrupts(s, m)) {
+			break
+		}
+		if marker, _ := itemMarker(s, m); r.blank && r.sibling == marker {
+			loose = true
+		}
+		rest = r.item(n, s, m)
+		opened = true
+	}
+	if loose {
+		r.emit("<p>")
+	}
+
+	if strings.TrimSpace(rest) == "" {
+		r.para = false
+		r.note = false
+		r.table = nil
+		r.blank = !opened
+		return
+	}
+	r.content(rest)
+	r.blank = false
+}
+
+// dedent removes width columns of leading whitespace from the line.
+func dedent(line string, width int) string {
+	n := 0
+	for i := 0; i < len(line); i++ {
+		if n >= width {
+			return line[i:]
+		}
+		switch line[i] {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+			if n > width {
+				return strings.Repeat(" ", n-width) + line[i+1:]
+			}
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// close ends the containers after the first n, remembering the list item at n
+// which the next item may continue.
+func (r *rewriter) close(n int) {
+	r.sibling = 0
+	if n == len(r.open) {
+		return
+	}
+	r.closeBlocks()
+	if !r.open[n].quote {
+		r.sibling = r.open[n].marker
+	}
+	r.open = r.open[:n]
+}
+
+// opens returns true if the line, which doesn't continue all the open
+// containers, opens a container. As it isn't within the paragraph's own
+// container, any list item may start.
+func opens(line string) bool {
+	n, s := indent(line)
+	if n >= 4 || breakRe.MatchString(strings.TrimRight(s, " \t")) {
+		return false
+	}
+	return strings.HasPrefix(s, ">") || itemRe.MatchString(s)
+}
+
+// interrupts returns true if the list item may interrupt a paragraph, which
+// it may only do if it isn't empty and, if it's ordered, starts at 1.
+func interrupts(s string, m []int) bool {
+	if strings.TrimSpace(s[m[1]:]) == "" {
+		return false
+	}
+	return m[4] == -1 || strings.TrimLeft(s[m[4]:m[5]], "0") == "1"
+}
+
+// continues returns true if the line may lazily continue a paragraph, so it
+// doesn't start another block. Attribute lists add to the last block instead.
+func continues(line string) bool {
+	n, s := indent(line)
+	if n >= 4 {
+		return true
+	}
+	if _, ok := attrs(strings.TrimSpace(s)); ok {
+		return false
+	}
+	if breakRe.MatchString(strings.TrimRight(s, " \t")) || headingRe.MatchString(s) ||
+		fenceRe.MatchString(s) || strings.HasPrefix(s, "<!--") {
+		return false
+	}
+	m := htmlBlockRe.FindStringSubmatch(s)
+	return m == nil || !blockTags[strings.ToLower(m[1])]
+}
+
+// leaf continues a code or HTML block.
+func (r *rewriter) leaf(line string, blank bool) {
+	if r.inHTML {
+		if r.htmlEnd == "" && blank {
+			r.inHTML = false
+			r.blank = true
+			return
+		}
+		r.emit(line, "\n")
+		if r.htmlEnd != "" && strings.Contains(strings.ToLower(line), r.htmlEnd) {
+			r.inHTML = false
+		}
+		return
+	}
+	_, s := indent(line)
+	if strings.HasPrefix(s, r.fence) && strings.Trim(s, r.fence[:1]+" \t") == "" {
+		r.fence = ""
+	}
+}
+
+// content rewrites the contents of a line within its containers.
+func (r *rewriter) content(line string) {
+	width, s := indent(line)
+
+	if width >= 4 {
+		if r.para {
+			r.inline(s)
+			return
+		}
+		if !r.code {
+			r.closeBlocks()
+			r.block("pre", "")
+			r.emit("<code>")
+			r.code = true
+		}
+		return
+	}
+	r.code = false
+
+	if r.table != nil {
+		if strings.Contains(s, "|") {
+			r.row(s, "td")
+			return
+		}
+		r.table = nil
+	}
+
+	switch {
+	case r.para && (strings.Trim(s, "= \t") == "" || strings.Trim(s, "- \t") == "") &&
+		strings.Trim(s, " \t") != "" && !strings.Contains(strings.TrimSpace(s), " "):
+		// setext headings
+		r.setext(s[0])
+		return
+	case breakRe.MatchString(strings.TrimRight(s, " \t")):
+		r.closeBlocks()
+		r.block("hr", "")
+		return
+	}
+
+	if m := headingRe.FindStringSubmatch(s); m != nil {
+		r.closeBlocks()
+		text, a := trailingAttrs(s[len(m[0]):])
+		text = closingRe.ReplaceAllString(text, "")
+		r.block("h"+string('0'+byte(len(m[1]))), a)
+		r.inline(text)
+		return
+	}
+
+	if m := fenceRe.FindStringSubmatch(s); m != nil && !(m[1][0] == '`' && strings.Contains(m[2], "`")) {
+		r.closeBlocks()
+		r.fence = m[1]
+		info, a := trailingAttrs(m[2])
+		if strings.HasPrefix(info, "{") {
+			info = ""
+		}
+		r.block("pre", a)
+		if lang := strings.Fields(info); len(lang) > 0 {
+			r.emit(`<code class="language-`, html.EscapeString(lang[0]), `">`)
+		} else {
+			r.emit("<code>")
+		}
+		return
+	}
+
+	if r.htmlBlock(s) {
+		return
+	}
+
+	if !r.blank && r.pos > 0 && r.lastBlock >= 0 {
+		if a, ok := attrs(strings.TrimSpace(s)); ok {
+			r.addAttrs(a)
+			r.para = false
+			return
+		}
+	}
+
+	if m := refDefRe.FindStringSubmatch(s); m != nil && (!r.para || r.note) {
+		label := m[1]
+		if strings.HasPrefix(label, "^") {
+			r.footnote(label, s[len(m[0])-1:])
+		}
+		return
+	}
+
+	if r.tableStart(s) {
+		return
+	}
+
+	if !r.para {
+		r.para = true
+		// paragraphs in list items only have a <p> if their list is loose
+		r.bare = len(r.open) > 0 && !r.open[len(r.open)-1].quote
+		if !r.bare {
+			r.block("p", "")
+		}
+	}
+	r.paraLine(s)
+}
+
+// paraLine rewrites a line of a paragraph, with hard line breaks.
+func (r *rewriter) paraLine(s string) {
+	if strings.HasSuffix(s, "  ") || strings.HasSuffix(s, "\\") {
+		r.inline(strings.TrimSuffix(s, "\\"))
+		r.emit("<br>")
+		return
+	}
+	r.inline(s)
+}
+
+// setext turns the paragraph into a heading.
+func (r *rewriter) setext(underline byte) {
+	tag := "h2"
+	if underline == '=' {
+		tag = "h1"
+	}
+	start := r.lastBlock - 2
+	if r.bare {
+		r.block(tag, "")
+	} else if start >= 0 && bytes.HasPrefix(r.out[start:], []byte("<p>")) {
+		r.out = append(r.out[:start], append([]byte("<"+tag+">"), r.out[start+3:]...)...)
+		r.lastBlock = start + 1 + len(tag)
+	}
+	r.para = false
+}
+
+// itemMarker returns the marker of the list item, which is the delimiter for
+// ordered items, and where it ends.
+func itemMarker(s string, m []int) (byte, int) {
+	if m[2] != -1 {
+		return s[m[2]], m[3]
+	}
+	return s[m[6]], m[7]
+}
+
+// item opens a list item, starting its list unless it continues the list of
+// the item just closed, and returns the rest of the line as its content.
+func (r *rewriter) item(width int, s string, m []int) string {
+	r.closeBlocks()
+	marker, markerEnd := itemMarker(s, m)
+	rest := s[m[1]:]
+	contentIndent := width + m[1]
+	if strings.TrimSpace(rest) == "" || m[1]-markerEnd > 4 {
+		// empty items, and items starting with indented code, have their content
+		// after a single space
+		contentIndent = width + markerEnd + 1
+		rest = dedent(s[markerEnd:], 1)
+	}
+
+	if r.sibling != marker {
+		switch {
+		case marker == '.' || marker == ')':
+			if start := strings.TrimLeft(s[m[4]:m[5]], "0"); start != "1" {
+				r.block("ol", ` start="`+start+`"`)
+			} else {
+				r.block("ol", "")
+			}
+		default:
+			r.block("ul", "")
+		}
+	}
+	r.open = append(r.open, container{indent: contentIndent, marker: marker})
+	r.sibling = 0
+	r.emit("<li>")
+
+	if t := taskRe.FindStringSubmatch(rest); t != nil {
+		if t[1] == " " {
+			r.emit(`<input disabled="" type="checkbox">`)
+		} else {
+			r.emit(`<input checked="" disabled="" type="checkbox">`)
+		}
+		rest = rest[len(t[0]):]
+	}
+	return rest
+}
+
+// htmlBlock rewrites the start of an HTML block, returning false if there
+// isn't one.
+func (r *rewriter) htmlBlock(s string) bool {
+	switch {
+	case strings.HasPrefix(s, "<!--"):
+		r.htmlEnd = "-->"
+	case strings.HasPrefix(s, "<?"):
+		r.htmlEnd = "?>"
+	case strings.HasPrefix(s, "<![CDATA["):
+		r.htmlEnd = "]]>"
+	case strings.HasPrefix(s, "<!") && len(s) > 2 && s[2] >= 'A' && s[2] <= 'Z':
+		r.htmlEnd = ">"
+	default:
+		m := htmlBlockRe.FindStringSubmatch(s)
+		if m == nil {
+			return false
+		}
+		name := strings.ToLower(m[1])
+		switch {
+		case rawTags[name] && s[1] != '/':
+			r.htmlEnd = "</" + name + ">"
+		case blockTags[name]:
+			r.htmlEnd = ""
+		case r.para:
+			// other tags are inline within paragraphs
+			return false
+		default:
+			r.htmlEnd = ""
+		}
+	}
+	r.closeBlocks()
+	r.inHTML = true
+	r.leaf(s, false)
+	return true
+}
+
+// tableStart rewrites the header row of a table, returning false if the line
+// isn't one.
+func (r *rewriter) tableStart(s string) bool {
+	if !strings.Contains(s, "|") || r.pos+1 >= len(r.lines) {
+		return false
+	}
+	next := strings.TrimSpace(strings.TrimLeft(r.lines[r.pos+1], " \t>"))
+	if !delimRe.MatchString(next) {
+		return false
+	}
+	header := cells(s)
+	delims := cells(next)
+	if len(header) != len(delims) {
+		return false
+	}
+	r.closeBlocks()
+	align := make([]string, len(delims))
+	for i, d := range delims {
+		d = strings.TrimSpace(d)
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			align[i] = ` style="text-align:center"`
+		case left:
+			align[i] = ` style="text-align:left"`
+		case right:
+			align[i] = ` style="text-align:right"`
+		}
+	}
+	r.block("table", "")
+	r.emit("<thead>")
+	r.table = align
+	r.row(s, "th")
+	r.emit("<tbody>")
+	r.pos++
+	return true
+}
+
+// row rewrites a row of the table.
+func (r *rewriter) row(s, cell string) {
+	r.emit("<tr>")
+	for i, c := range cells(s) {
+		if i >= len(r.table) {
+			break
+		}
+		r.emit("<", cell, r.table[i], ">")
+		r.inline(c)
+	}
+}
+
+// cells splits a table row into its cells.
+func cells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cs []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+		case '|':
+			cs = append(cs, row[start:i])
+			start = i + 1
+		}
+	}
+	return append(cs, row[start:])
+}
+
+// footnote rewrites a footnote definition, starting the footnotes section
+// before the first.
+func (r *rewriter) footnote(label, text string) {
+	r.closeBlocks()
+	if !r.footnotes {
+		r.footnotes = true
+		r.emit(`<div class="footnotes" role="doc-endnotes"><hr><ol>`)
+	}
+	r.emit("<li>")
+	r.block("p", "")
+	r.para = true
+	r.bare = false
+	r.note = true
+	r.inline(text)
+	r.emit(`<a class="footnote-backref" href="#fnref" role="doc-backlink">`)
+}
+
+// run returns the length of the run of c starting at i.
+func run(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func isAlnum(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// codeEnd returns the position after the code span starting at i, or -1 if it
+// isn't closed.
+func codeEnd(s string, i int) int {
+	n := run(s, i, '`')
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		m := run(s, j, '`')
+		if m == n {
+			return j + m
+		}
+		j += m
+	}
+	return -1
+}
+
+// closer returns the position of the run of c closing the emphasis opened at
+// i, and its length, or -1 if there isn't one.
+func closer(s string, i int, c byte) (int, int) {
+	for j := i + run(s, i, c); j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			if end := codeEnd(s, j); end != -1 {
+				j = end - 1
+			}
+		case c:
+			m := run(s, j, c)
+			if !isSpace(s[j-1]) && (c != '_' || j+m == len(s) || !isAlnum(s[j+m])) {
+				return j, m
+			}
+			j += m - 1
+		}
+	}
+	return -1, 0
+}
+
+// inline rewrites the inline elements in the text.
+func (r *rewriter) inline(s string) {
+	closers := make(map[int]bool)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '`':
+			if end := codeEnd(s, i); end != -1 {
+				r.emit("<code>")
+				i = end - 1
+			} else {
+				i += run(s, i, c) - 1
+			}
+		case c == '<':
+			i += r.inlineHTML(s[i:])
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			i += r.link(s[i+1:], true)
+		case c == '[':
+			i += r.link(s[i:], false) - 1
+		case c == '*' || c == '_' || c == '~':
+			n := run(s, i, c)
+			if closers[i] {
+				i += n - 1
+				continue
+			}
+			opens := i+n < len(s) && !isSpace(s[i+n]) && (c != '_' || i == 0 || !isAlnum(s[i-1]))
+			if j, m := closer(s, i, c); opens && j != -1 {
+				closers[j] = true
+				k := n
+				if m < k {
+					k = m
+				}
+				switch {
+				case c == '~':
+					if n == m && n <= 2 {
+						r.emit("<del>")
+					}
+				case k >= 3:
+					r.emit("<em><strong>")
+				case k == 2:
+					r.emit("<strong>")
+				default:
+					r.emit("<em>")
+				}
+			}
+			i += n - 1
+		case (c == 'h' || c == 'w') && (i == 0 || !isAlnum(s[i-1])):
+			if url := bareURLRe.FindString(s[i:]); url != "" {
+				r.emit(`<a href="`, html.EscapeString(url), `">`)
+				i += len(url) - 1
+			}
+		}
+	}
+}
+
+// inlineHTML rewrites the autolink or raw HTML at the start of the text,
+// returning the number of bytes used after the first.
+func (r *rewriter) inlineHTML(s string) int {
+	if strings.HasPrefix(s, "<!--") {
+		end := strings.Index(s, "-->")
+		if end == -1 {
+			return 0
+		}
+		r.emit(s[:end+3])
+		return end + 2
+	}
+	if end := strings.IndexByte(s, '>'); end != -1 {
+		if inner := s[1:end]; schemeRe.MatchString(inner) || emailRe.MatchString(inner) {
+			r.emit(`<a href="`, html.EscapeString(inner), `">`)
+			return end
+		}
+	}
+	if htmlBlockRe.FindString(s) == "" {
+		return 0
+	}
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			r.emit(s[:i+1])
+			return i
+		}
+	}
+	return 0
+}
+
+// bracket returns the position of the ']' closing the '[' at the start of the
+// text, or -1 if there isn't one.
+func bracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			if end := codeEnd(s, i); end != -1 {
+				i = end - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// link rewrites the link, image or footnote reference at the start of the
+// text, returning the number of bytes used or 1 if there isn't one.
+func (r *rewriter) link(s string, image bool) int {
+	end := bracket(s)
+	if end == -1 {
+		return 1
+	}
+	text := s[1:end]
+	var a string
+	switch {
+	case strings.HasPrefix(text, "^") && !image:
+		if !r.notes[refKey(text[1:])] {
+			return 1
+		}
+		r.emit(`<sup><a class="footnote-ref" href="#fn" role="doc-noteref">`)
+		return end + 1
+	case end+1 < len(s) && s[end+1] == '(':
+		depth := 0
+		close := -1
+	dest:
+		for i := end + 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					close = i
+					break dest
+				}
+			}
+		}
+		if close == -1 {
+			return 1
+		}
+		fields := strings.Fields(s[end+2 : close])
+		if len(fields) > 0 {
+			a = ` href="` + html.EscapeString(strings.Trim(fields[0], "<>")) + `"`
+		}
+		if len(fields) > 1 {
+			a += ` title=""`
+		}
+		end = close
+	case end+1 < len(s) && s[end+1] == '[':
+		label := bracket(s[end+1:])
+		if label == -1 {
+			return 1
+		}
+		key := refKey(s[end+2 : end+1+label])
+		if key == "" {
+			key = refKey(text)
+		}
+		if !r.refs[key] {
+			return 1
+		}
+		a = ` href=""`
+		end += 1 + label
+	default:
+		if !r.refs[refKey(text)] {
+			return 1
+		}
+		a = ` href=""`
+	}
+	if end+1 < len(s) && s[end+1] == '{' {
+		if close := strings.IndexByte(s[end+1:], '}'); close != -1 {
+			if extra, ok := attrs(s[end+1 : end+2+close]); ok {
+				a += extra
+				end += 1 + close
+			}
+		}
+	}
+	if image {
+		r.emit(`<img alt=""`, strings.Replace(a, "href=", "src=", 1), ">")
+	} else {
+		r.emit("<a", a, ">")
+		r.inline(text)
+	}
+	return end + 1
+}
+
+// rewrite returns the HTML elements in the document.
+func rewrite(doc string) []byte {
+	r := rewriter{
+		lines:     strings.Split(frontMatter(doc), "\n"),
+		refs:      make(map[string]bool),
+		notes:     make(map[string]bool),
+		lastBlock: -1,
+	}
+	for _, line := range r.lines {
+		if m := refDefRe.FindStringSubmatch(strings.TrimLeft(line, " \t>")); m != nil {
+			if strings.HasPrefix(m[1], "^") {
+				r.notes[refKey(m[1][1:])] = true
+			} else {
+				r.refs[refKey(m[1])] = true
+			}
+		}
+	}
+	for ; r.pos < len(r.lines); r.pos++ {
+		r.line(r.lines[r.pos])
+	}
+	return r.out
+}
+
+// Extract returns the usage information for a Markdown file.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return htmlusage.Extract(bytes.NewReader(rewrite(string(data))))
+}
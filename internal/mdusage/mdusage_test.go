@@ -0,0 +1,272 @@
+package mdusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		md   string
+		seen []cssselector.Selector
+	}{
+		{
+			name: "paragraphs and headings",
+			md: `---
+title: Front Matter
+---
+# Title {#top .hero}
+
+Sub
+---
+
+Text with *em*, __strong__ and ~~del~~.
+`,
+			seen: []cssselector.Selector{
+				{Tag: "h1", ID: "top", Class: set("hero")},
+				{Tag: "h2"},
+				{Tag: "p"},
+				{Tag: "em"},
+				{Tag: "strong"},
+				{Tag: "del"},
+			},
+		},
+		{
+			name: "lists and task lists",
+			md: `- one
+- [x] done
+  1. nested
+
+3) three
+`,
+			seen: []cssselector.Selector{
+				{Tag: "ul"},
+				{Tag: "li"},
+				{Tag: "input", Attr: set("checked", "disabled", "type")},
+				{Tag: "ol"},
+				{Tag: "ol", Attr: set("start")},
+			},
+		},
+		{
+			name: "tables",
+			md: `| a | b |
+|:--|---|
+| 1 | ` + "`2`" + ` |
+`,
+			seen: []cssselector.Selector{
+				{Tag: "table"},
+				{Tag: "thead"},
+				{Tag: "tr"},
+				{Tag: "th", Attr: set("style")},
+				{Tag: "th"},
+				{Tag: "tbody"},
+				{Tag: "td", Attr: set("style")},
+				{Tag: "td"},
+				{Tag: "code"},
+			},
+		},
+		{
+			name: "code blocks",
+			md:   "```go {.wide}\n<div class=\"ignored\">\n```\n\n    <i>indented</i>\n\n~~~\nplain\n~~~\n",
+			seen: []cssselector.Selector{
+				{Tag: "pre", Class: set("wide")},
+				{Tag: "code", Class: set("language-go")},
+				{Tag: "pre"},
+				{Tag: "code"},
+			},
+		},
+		{
+			name: "links and images",
+			md: `[home](/ "Home"){.nav} ![logo](logo.png) <https://example.com>
+[ref][r] and [^1] but not [missing].
+
+[r]: /r
+[^1]: The note.
+`,
+			seen: []cssselector.Selector{
+				{Tag: "p"},
+				{Tag: "a", Class: set("nav"), Attr: set("href", "title")},
+				{Tag: "img", Attr: set("alt", "src")},
+				{Tag: "a", Attr: set("href")},
+				{Tag: "sup"},
+				{Tag: "a", Class: set("footnote-ref"), Attr: set("href", "role")},
+				{Tag: "div", Class: set("footnotes"), Attr: set("role")},
+				{Tag: "hr"},
+				{Tag: "ol"},
+				{Tag: "li"},
+				{Tag: "a", Class: set("footnote-backref"), Attr: set("href", "role")},
+			},
+		},
+		{
+			name: "raw html",
+			md: `<div class="box">
+*not markdown*
+</div>
+
+Inline <span class="hl">html</span> but ` + "`<b>code</b>`" + `.
+`,
+			seen: []cssselector.Selector{
+				{Tag: "div", Class: set("box")},
+				{Tag: "p"},
+				{Tag: "span", Class: set("hl")},
+				{Tag: "code"},
+			},
+		},
+		{
+			name: "block attributes",
+			md: `> quoted
+{.note}
+
+A {placeholder} paragraph.
+`,
+			seen: []cssselector.Selector{
+				{Tag: "blockquote"},
+				{Tag: "p", Class: set("note")},
+				{Tag: "p"},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.md))
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+var openTagRe = regexp.MustCompile(`<([a-z][a-z0-9]*)((?:\s+[a-z-]+(?:="[^"]*")?)*)\s*/?>`)
+
+// openTags returns the distinct opening tags in the HTML, which is all that's
+// seen by the HTML extractor.
+func openTags(html string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, m := range openTagRe.FindAllStringSubmatch(html, -1) {
+		tag := m[1] + m[2]
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TestCommonMark checks the elements produced for examples from the
+// CommonMark spec, with the HTML it gives for them.
+func TestCommonMark(t *testing.T) {
+	cases := []struct {
+		md, html string
+	}{
+		// thematic breaks and setext headings
+		{"***\n---\n___\n", "<hr /><hr /><hr />"},
+		{"Foo\n***\nbar\n", "<p>Foo</p><hr /><p>bar</p>"},
+		{"Foo\n---\nbar\n", "<h2>Foo</h2><p>bar</p>"},
+		{"Foo\nbar\n===\n", "<h1>Foo\nbar</h1>"},
+		{"Foo\n   ----      \n", "<h2>Foo</h2>"},
+		{"Foo\n= =\n", "<p>Foo\n= =</p>"},
+		{"\n====\n", "<p>====</p>"},
+		{"    foo\n---\n", "<pre><code>foo\n</code></pre><hr />"},
+		{"* Foo\n* * *\n* Bar\n", "<ul><li>Foo</li></ul><hr /><ul><li>Bar</li></ul>"},
+		{"- Foo\n- * * *\n", "<ul><li>Foo</li><li><hr /></li></ul>"},
+		{"- Foo\n---\n", "<ul><li>Foo</li></ul><hr />"},
+		{"> Foo\n---\n", "<blockquote><p>Foo</p></blockquote><hr />"},
+		{"- # Foo\n- Bar\n  ---\n  baz\n", "<ul><li><h1>Foo</h1></li><li><h2>Bar</h2>baz</li></ul>"},
+
+		// block quotes and lazy continuation
+		{"> # Foo\n> bar\n> baz\n", "<blockquote><h1>Foo</h1><p>bar\nbaz</p></blockquote>"},
+		{"> # Foo\n> bar\nbaz\n", "<blockquote><h1>Foo</h1><p>bar\nbaz</p></blockquote>"},
+		{"> bar\nbaz\n> foo\n", "<blockquote><p>bar\nbaz\nfoo</p></blockquote>"},
+		{"> - foo\n- bar\n", "<blockquote><ul><li>foo</li></ul></blockquote><ul><li>bar</li></ul>"},
+		{">     foo\n    bar\n", "<blockquote><pre><code>foo\n</code></pre></blockquote><pre><code>bar\n</code></pre>"},
+		{"> ```\nfoo\n```\n", "<blockquote><pre><code></code></pre></blockquote><p>foo</p><pre><code></code></pre>"},
+		{"> foo\n    - bar\n", "<blockquote><p>foo\n- bar</p></blockquote>"},
+		{">\n", "<blockquote></blockquote>"},
+		{"> bar\n\nbaz\n", "<blockquote><p>bar</p></blockquote><p>baz</p>"},
+		{"> > > foo\nbar\n", "<blockquote><blockquote><blockquote><p>foo\nbar</p></blockquote></blockquote></blockquote>"},
+		{">     code\n\n>    not code\n", "<blockquote><pre><code>code\n</code></pre></blockquote><blockquote><p>not code</p></blockquote>"},
+
+		// list items and their nesting
+		{"- one\n\n two\n", "<ul><li>one</li></ul><p>two</p>"},
+		{"- one\n\n  two\n", "<ul><li><p>one</p><p>two</p></li></ul>"},
+		{" -    one\n\n     two\n", "<ul><li>one</li></ul><pre><code> two\n</code></pre>"},
+		{"   > > 1.  one\n>>\n>>     two\n", "<blockquote><blockquote><ol><li><p>one</p><p>two</p></li></ol></blockquote></blockquote>"},
+		{"-one\n\n2.two\n", "<p>-one</p><p>2.two</p>"},
+		{"1.  A paragraph\n    with two lines.\n\n        indented code\n\n    > A block quote.\n",
+			"<ol><li><p>A paragraph\nwith two lines.</p><pre><code>indented code\n</code></pre><blockquote><p>A block quote.</p></blockquote></li></ol>"},
+		{"  1.  A paragraph\nwith two lines.\n", "<ol><li>A paragraph\nwith two lines.</li></ol>"},
+		{"> 1. > Blockquote\ncontinued here.\n", "<blockquote><ol><li><blockquote><p>Blockquote\ncontinued here.</p></blockquote></li></ol></blockquote>"},
+		{"- foo\n  - bar\n    - baz\n      - boo\n", "<ul><li>foo<ul><li>bar<ul><li>baz<ul><li>boo</li></ul></li></ul></li></ul></li></ul>"},
+		{"10) foo\n    - bar\n", `<ol start="10"><li>foo<ul><li>bar</li></ul></li></ol>`},
+		{"- a\n - b\n  - c\n   - d\n    - e\n", "<ul><li>a</li><li>b</li><li>c</li><li>d\n- e</li></ul>"},
+		{"1. a\n\n  2. b\n\n    3. c\n", "<ol><li><p>a</p></li><li><p>b</p></li></ol><pre><code>3. c\n</code></pre>"},
+		{"1. ```\n   foo\n   ```\n\n   bar\n", "<ol><li><pre><code>foo\n</code></pre><p>bar</p></li></ol>"},
+
+		// lists interrupting paragraphs, and loose lists
+		{"The number of windows in my house is\n14.  The number of doors is 6.\n", "<p>The number of windows in my house is\n14.  The number of doors is 6.</p>"},
+		{"The number of windows in my house is\n1.  The number of doors is 6.\n", "<p>The number of windows in my house is</p><ol><li>The number of doors is 6.</li></ol>"},
+		{"Foo\n- bar\n- baz\n", "<p>Foo</p><ul><li>bar</li><li>baz</li></ul>"},
+		{"- foo\n- bar\n+ baz\n", "<ul><li>foo</li><li>bar</li></ul><ul><li>baz</li></ul>"},
+		{"1. foo\n2. bar\n3) baz\n", `<ol><li>foo</li><li>bar</li></ol><ol start="3"><li>baz</li></ol>`},
+		{"- a\n- b\n\n- c\n", "<ul><li><p>a</p></li><li><p>b</p></li><li><p>c</p></li></ul>"},
+		{"* a\n*\n\n* c\n", "<ul><li><p>a</p></li><li></li><li><p>c</p></li></ul>"},
+		{"- a\n- b\n\n  c\n- d\n", "<ul><li><p>a</p></li><li><p>b</p><p>c</p></li><li><p>d</p></li></ul>"},
+		{"- a\n  > b\n  ```\n  c\n  ```\n- d\n", "<ul><li>a<blockquote><p>b</p></blockquote><pre><code>c\n</code></pre></li><li>d</li></ul>"},
+		{"- a\n  - b\n\n    c\n- d\n", "<ul><li>a<ul><li><p>b</p><p>c</p></li></ul></li><li>d</li></ul>"},
+	}
+	for _, c := range cases {
+		ensure.DeepEqual(t, openTags(string(rewrite(c.md))), openTags(c.html), c.md)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	cases := []struct {
+		list  string
+		attrs string
+		ok    bool
+	}{
+		{list: "{.a .b #c}", attrs: ` class="a b" id="c"`, ok: true},
+		{list: "{: .a}", attrs: ` class="a"`, ok: true},
+		{list: `{class="a b" data-x=1 .c}`, attrs: ` class="a b c" data-x="1"`, ok: true},
+		{list: "{word}"},
+		{list: "{.a"},
+	}
+	for _, c := range cases {
+		a, ok := attrs(c.list)
+		ensure.DeepEqual(t, ok, c.ok, c.list)
+		ensure.DeepEqual(t, a, c.attrs, c.list)
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-mdusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
@@ -0,0 +1,98 @@
+// Package statsusage imports usage information from lists of the tags, classes
+// and IDs used across a site, such as the hugo_stats.json file written by Hugo.
+package statsusage
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/pkg/errors"
+)
+
+// Info holds the tags, classes and IDs seen. Since the lists don't record
+// which of them are used together, a chain is included if each of its parts is
+// seen anywhere. Attributes aren't recorded, so they are always considered
+// seen.
+type Info struct {
+	Tags    map[string]struct{}
+	Classes map[string]struct{}
+	IDs     map[string]struct{}
+}
+
+func contains(set map[string]struct{}, k string) bool {
+	_, found := set[k]
+	return found
+}
+
+func (i *Info) Includes(chain cssselector.Chain) bool {
+	for _, s := range chain {
+		if s.Tag != "" && !contains(i.Tags, s.Tag) {
+			return false
+		}
+		if s.ID != "" && !contains(i.IDs, s.ID) {
+			return false
+		}
+		for c := range s.Class {
+			if !contains(i.Classes, c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func merge(dst *map[string]struct{}, src map[string]struct{}) {
+	if len(src) > 0 && *dst == nil {
+		*dst = make(map[string]struct{})
+	}
+	for k := range src {
+		(*dst)[k] = struct{}{}
+	}
+}
+
+func (i *Info) Merge(other *Info) {
+	merge(&i.Tags, other.Tags)
+	merge(&i.Classes, other.Classes)
+	merge(&i.IDs, other.IDs)
+}
+
+// lists is the generic format, which is also nested in hugo_stats.json.
+type lists struct {
+	Tags    []string `json:"tags"`
+	Classes []string `json:"classes"`
+	IDs     []string `json:"ids"`
+}
+
+func set(values []string) map[string]struct{} {
+	s := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		s[strings.ToLower(v)] = struct{}{}
+	}
+	return s
+}
+
+// Extract reads either the hugo_stats.json format, where the lists are within
+// an htmlElements object, or the generic format with just the lists:
+//
+//	{"tags": ["a", "div"], "classes": ["card"], "ids": ["main"]}
+func Extract(r io.Reader) (*Info, error) {
+	var stats struct {
+		HTMLElements *lists `json:"htmlElements"`
+		lists
+	}
+	if err := json.NewDecoder(r).Decode(&stats); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	l := &stats.lists
+	if stats.HTMLElements != nil {
+		l = stats.HTMLElements
+	}
+	return &Info{
+		Tags:    set(l.Tags),
+		Classes: set(l.Classes),
+		IDs:     set(l.IDs),
+	}, nil
+}
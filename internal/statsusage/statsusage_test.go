@@ -0,0 +1,80 @@
+package statsusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func chain(t *testing.T, selector string) cssselector.Chain {
+	chains, err := cssselector.Parse(strings.NewReader(selector))
+	ensure.Nil(t, err)
+	return chains
+}
+
+func TestIncludes(t *testing.T) {
+	cases := []struct {
+		name  string
+		stats string
+	}{
+		{
+			name: "hugo",
+			stats: `{
+  "htmlElements": {
+    "tags": ["a", "DIV", "nav"],
+    "classes": ["card", "Active"],
+    "ids": ["main"]
+  }
+}`,
+		},
+		{
+			name:  "generic",
+			stats: `{"tags": ["a", "div", "nav"], "classes": ["card", "active"], "ids": ["main"]}`,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.stats))
+			ensure.Nil(t, err)
+			for _, s := range []string{"div", "div.card", ".card.active", "nav a", "#main", "a[href]", "div.active#main"} {
+				ensure.True(t, info.Includes(chain(t, s)), s)
+			}
+			for _, s := range []string{"span", "div.other", ".card.other", "nav span", "#other", "p[href]"} {
+				ensure.False(t, info.Includes(chain(t, s)), s)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var info Info
+	a, err := Extract(strings.NewReader(`{"tags": ["a"]}`))
+	ensure.Nil(t, err)
+	b, err := Extract(strings.NewReader(`{"classes": ["card"]}`))
+	ensure.Nil(t, err)
+	info.Merge(a)
+	info.Merge(b)
+	ensure.True(t, info.Includes(chain(t, "a.card")))
+	ensure.False(t, info.Includes(chain(t, "div.card")))
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := Extract(strings.NewReader(`{"tags": "a"}`))
+	ensure.NotNil(t, err)
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-statsusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
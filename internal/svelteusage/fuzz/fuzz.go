@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/svelteusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = svelteusage.Extract(bytes.NewReader(b))
+	return 0
+}
@@ -0,0 +1,306 @@
+// Package svelteusage extracts usage information from Svelte components, and
+// purges their <style> blocks. The markup is rewritten as plain HTML before it
+// is read. Logic blocks like {#if} are masked so the markup in all branches is
+// seen, and the string literals in interpolated attribute values are read as
+// literal parts of the value.
+package svelteusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/csspurge"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+	"github.com/daaku/cssdalek/internal/jsxusage"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	commentStartB = []byte("<!--")
+	commentEndB   = []byte("-->")
+	classDirB     = []byte("class:")
+	onDirB        = []byte("on:")
+	bindDirB      = []byte("bind:")
+	classB        = []byte("class")
+	onB           = []byte("on")
+	langRe        = regexp.MustCompile(`(?i)\slang\s*=\s*["']?([a-z]*)`)
+)
+
+// span is the range of bytes containing the contents of a <style> block.
+type span struct {
+	start, end int
+	css        bool // false for other languages like SCSS
+}
+
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b == ':' || b == '.'
+}
+
+// hasTagPrefix returns true if the data starts with the tag name, which is
+// compared case insensitively.
+func hasTagPrefix(data []byte, name string) bool {
+	return len(data) > len(name) && bytes.EqualFold(data[:len(name)], []byte(name)) &&
+		!isNameByte(data[len(name)])
+}
+
+// exprEnd returns the position after the '}' closing the expression starting
+// after the '{' at pos.
+func exprEnd(data []byte, pos int) int {
+	depth := 0
+	for pos++; pos < len(data); pos++ {
+		switch c := data[pos]; c {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return pos + 1
+			}
+			depth--
+		case '"', '\'':
+			for pos++; pos < len(data) && data[pos] != c; pos++ {
+				if data[pos] == '\\' {
+					pos++
+				}
+			}
+		case '`':
+			for pos++; pos < len(data) && data[pos] != '`'; pos++ {
+				switch {
+				case data[pos] == '\\':
+					pos++
+				case data[pos] == '$' && pos+1 < len(data) && data[pos+1] == '{':
+					pos = exprEnd(data, pos+1) - 1
+				}
+			}
+		}
+	}
+	return len(data)
+}
+
+// classes returns the classes in an expression, separated by spaces.
+func classes(expr []byte) []byte {
+	set := jsxusage.ClassExpr(expr, nil)
+	names := make([]string, 0, len(set))
+	for c := range set {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return []byte(strings.Join(names, " "))
+}
+
+type rewriter struct {
+	data   []byte
+	pos    int
+	out    bytes.Buffer
+	styles []span
+}
+
+// rewrite rewrites the markup as plain HTML, leaving out the <script> and
+// <style> blocks and recording the spans of the latter.
+func (r *rewriter) rewrite() {
+	for r.pos < len(r.data) {
+		switch c := r.data[r.pos]; {
+		case bytes.HasPrefix(r.data[r.pos:], commentStartB):
+			end := bytes.Index(r.data[r.pos:], commentEndB)
+			if end == -1 {
+				r.pos = len(r.data)
+			} else {
+				r.pos += end + len(commentEndB)
+			}
+		case c == '<' && hasTagPrefix(r.data[r.pos+1:], "script"):
+			r.block("script")
+		case c == '<' && hasTagPrefix(r.data[r.pos+1:], "style"):
+			r.styles = append(r.styles, r.block("style"))
+		case c == '<' && r.pos+1 < len(r.data) && (r.data[r.pos+1] == '/' || isNameByte(r.data[r.pos+1])):
+			r.tag()
+		case c == '{':
+			// logic blocks and text expressions
+			r.pos = exprEnd(r.data, r.pos)
+			r.out.WriteByte(' ')
+		default:
+			r.out.WriteByte(c)
+			r.pos++
+		}
+	}
+}
+
+// block skips the block, returning the span of its contents.
+func (r *rewriter) block(name string) span {
+	var s span
+	end := r.tagEnd()
+	lang := langRe.FindSubmatch(r.data[r.pos:end])
+	s.css = lang == nil || strings.EqualFold(string(lang[1]), "css")
+	s.start = end
+	s.end = len(r.data)
+	r.pos = len(r.data)
+	for i := end; i < len(r.data); i++ {
+		if r.data[i] == '<' && i+1 < len(r.data) && r.data[i+1] == '/' && hasTagPrefix(r.data[i+2:], name) {
+			s.end = i
+			r.pos = i
+			r.pos = r.tagEnd()
+			break
+		}
+	}
+	return s
+}
+
+// tagEnd returns the position after the '>' ending the tag at the current
+// position, skipping over quoted values and expressions.
+func (r *rewriter) tagEnd() int {
+	var quote byte
+	for i := r.pos; i < len(r.data); i++ {
+		switch c := r.data[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			i = exprEnd(r.data, i) - 1
+		case c == '>':
+			return i + 1
+		}
+	}
+	return len(r.data)
+}
+
+// tag rewrites the tag at the current position. Expressions in attribute
+// values are replaced with their literals, and others are masked.
+func (r *rewriter) tag() {
+	var quote byte
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		switch {
+		case c == '{':
+			end := exprEnd(r.data, r.pos)
+			expr := bytes.TrimSuffix(r.data[r.pos+1:end], []byte("}"))
+			prev := r.out.Bytes()
+			switch {
+			case quote != 0:
+				r.out.WriteByte(' ')
+				r.out.Write(classes(expr))
+				r.out.WriteByte(' ')
+			case len(prev) > 0 && prev[len(prev)-1] == '=':
+				r.out.WriteByte('"')
+				r.out.Write(classes(expr))
+				r.out.WriteByte('"')
+			default:
+				// shorthand attributes and spreads
+				r.out.WriteByte(' ')
+			}
+			r.pos = end
+			continue
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			r.out.WriteByte(c)
+			r.pos++
+			return
+		}
+		r.out.WriteByte(c)
+		r.pos++
+	}
+}
+
+// attr reads class directives as classes, event handlers as on* attributes
+// and bindings as plain attributes.
+func attr(name, value []byte) ([]byte, []byte) {
+	switch {
+	case bytes.HasPrefix(name, classDirB):
+		return classB, name[len(classDirB):]
+	case bytes.HasPrefix(name, onDirB):
+		return append(append([]byte(nil), onB...), name[len(onDirB):]...), value
+	case bytes.HasPrefix(name, bindDirB):
+		return name[len(bindDirB):], value
+	}
+	return name, value
+}
+
+// extract returns the usage information for the component, and the spans of
+// its <style> blocks.
+func extract(doc []byte) (*htmlusage.Info, []span, error) {
+	r := rewriter{data: doc}
+	r.rewrite()
+	info, err := htmlusage.ExtractComponent(&r.out, attr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, r.styles, nil
+}
+
+// Extract returns the usage information for a component.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info, _, err := extract(doc)
+	return info, err
+}
+
+// Purge copies the component from r to w, purging the contents of each
+// <style> block. Styles are scoped to the component, so they are purged using
+// its own usage along with u. Styles in other languages are copied unchanged.
+// Unless it is nil, excluded wraps the usage once the component's own usage is
+// added, so exclusions apply to all of it. Unless it is nil, keep holds the
+// font faces, keyframes and rules kept regardless of usage.
+func Purge(u usage.Info, excluded func(usage.Info) usage.Info, keep *cssusage.Info, l *log.Logger, r io.Reader, w io.Writer) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, spans, err := extract(doc)
+	if err != nil {
+		return err
+	}
+
+	var cssInfo cssusage.Info
+	if keep != nil {
+		cssInfo.Merge(keep)
+	}
+	cssInfo.Merge(&info.Style)
+	for _, s := range spans {
+		if !s.css {
+			continue
+		}
+		c, err := cssusage.Extract(bytes.NewReader(doc[s.start:s.end]))
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", s.start)
+		}
+		cssInfo.Merge(c)
+	}
+
+	var su usage.Info = usage.MultiInfo{u, info}
+	if excluded != nil {
+		su = excluded(su)
+	}
+	last := 0
+	for _, s := range spans {
+		if !s.css {
+			continue
+		}
+		if _, err := w.Write(doc[last:s.start]); err != nil {
+			return errors.WithStack(err)
+		}
+		err := csspurge.Purge(su, &cssInfo, l, bytes.NewReader(doc[s.start:s.end]), w)
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", s.start)
+		}
+		last = s.end
+	}
+	_, err = w.Write(doc[last:])
+	return errors.WithStack(err)
+}
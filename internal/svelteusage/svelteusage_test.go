@@ -0,0 +1,165 @@
+package svelteusage
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/excludeusage"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name   string
+		svelte string
+		seen   []cssselector.Selector
+	}{
+		{
+			name:   "plain markup",
+			svelte: `<div class="a"><span>x</span></div>`,
+			seen: []cssselector.Selector{
+				{Tag: "div", Class: set("a")},
+				{Tag: "span"},
+			},
+		},
+		{
+			name:   "class directives",
+			svelte: `<button class="btn" class:active={current === 'a'} class:big>x</button>`,
+			seen: []cssselector.Selector{
+				{Tag: "button", Class: set("btn", "active", "big")},
+			},
+		},
+		{
+			name:   "interpolation",
+			svelte: `<p class="{size} card {open ? 'open' : 'closed'}">x</p>`,
+			seen: []cssselector.Selector{
+				{Tag: "p", Class: set("card", "open", "closed")},
+			},
+		},
+		{
+			name:   "components",
+			svelte: `<Button class="btn"><Icon.Close /></Button><button>`,
+			seen: []cssselector.Selector{
+				{Class: set("btn")},
+				{},
+				{Tag: "button"},
+			},
+		},
+		{
+			name:   "unquoted expression",
+			svelte: `<p class={cls('x')} id={'main'}>x</p>`,
+			seen: []cssselector.Selector{
+				{Tag: "p", ID: "main", Class: set("x")},
+			},
+		},
+		{
+			name: "logic blocks",
+			svelte: `{#if user}<b class="in">{user.name}</b>{:else if x > 1}<i>{x}</i>{:else}<u>{/if}
+{#each items as item (item.id)}<li class="item" {...item.props}>{item}</li>{/each}`,
+			seen: []cssselector.Selector{
+				{Tag: "b", Class: set("in")},
+				{Tag: "i"},
+				{Tag: "u"},
+				{Tag: "li", Class: set("item")},
+			},
+		},
+		{
+			name: "script and style",
+			svelte: `<script>const s = '<b class="no">'; let x = {a: 1}</script>
+<a on:click={() => go('<i>')} bind:value={v}>x</a>
+<style>.a { color: red }</style>`,
+			seen: []cssselector.Selector{
+				{Tag: "a", Attr: set("onclick", "value")},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.svelte))
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	svelte := `<p class:used={x}>x</p>
+<style>
+.used{color:red}.unused{color:red}
+</style>
+<style lang="scss">.unused{ .x { color: red } }</style>
+`
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, nil, nil, l, strings.NewReader(svelte), &out))
+	ensure.DeepEqual(t, out.String(), `<p class:used={x}>x</p>
+<style>.used{color:red;}</style>
+<style lang="scss">.unused{ .x { color: red } }</style>
+`)
+}
+
+// excludeLegacy excludes the classes starting with legacy.
+func excludeLegacy(u usage.Info) usage.Info {
+	return &excludeusage.Info{
+		Exclude: &excludeusage.ExcludeClass{Re: []*regexp.Regexp{regexp.MustCompile(`^legacy`)}},
+		Keep:    usage.MultiInfo{},
+		Usage:   u,
+	}
+}
+
+func TestPurgeExcluded(t *testing.T) {
+	svelte := `<p class="used legacy-box">x</p>
+<style>.used{color:red}.legacy-box{color:red}</style>
+`
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, excludeLegacy, nil, l, strings.NewReader(svelte), &out))
+	ensure.DeepEqual(t, out.String(), `<p class="used legacy-box">x</p>
+<style>.used{color:red;}</style>
+`)
+}
+
+func TestPurgeKeep(t *testing.T) {
+	svelte := `<p>x</p>
+<style>@keyframes spin{to{x:y}}@keyframes fade{to{x:y}}</style>
+`
+	keep := cssusage.Info{KeepKeyframesRe: []*regexp.Regexp{regexp.MustCompile(`^spin`)}}
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, nil, &keep, l, strings.NewReader(svelte), &out))
+	ensure.DeepEqual(t, out.String(), `<p>x</p>
+<style>@keyframes spin{to{x:y;}}</style>
+`)
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-svelteusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
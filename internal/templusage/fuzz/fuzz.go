@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/templusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = templusage.Extract(bytes.NewReader(b))
+	return 0
+}
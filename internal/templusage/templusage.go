@@ -0,0 +1,259 @@
+// Package templusage extracts usage information from templ files. The
+// templates are rewritten as plain HTML before they are read. Go expressions in
+// attribute values are replaced with the string literals in them, while
+// control flow, component calls and expressions in text are masked so the
+// markup in all branches is seen.
+package templusage
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/pkg/errors"
+)
+
+var templB = []byte("templ ")
+
+// statements which start a line of Go code within a template.
+var statements = [][]byte{
+	[]byte("if "),
+	[]byte("for "),
+	[]byte("switch "),
+	[]byte("case "),
+	[]byte("default:"),
+	[]byte("else "),
+	[]byte("} else"),
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// goEnd returns the position after the byte closing the Go code starting
+// after the bracket at pos, skipping over literals and nested brackets.
+func goEnd(data []byte, pos int) int {
+	depth := 0
+	for pos++; pos < len(data); pos++ {
+		switch c := data[pos]; c {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			if depth == 0 {
+				return pos + 1
+			}
+			depth--
+		case '"', '\'':
+			for pos++; pos < len(data) && data[pos] != c && data[pos] != '\n'; pos++ {
+				if data[pos] == '\\' {
+					pos++
+				}
+			}
+		case '`':
+			for pos++; pos < len(data) && data[pos] != '`'; pos++ {
+			}
+		}
+	}
+	return len(data)
+}
+
+// literals returns the contents of the string literals in the Go code,
+// separated by spaces.
+func literals(code []byte) []byte {
+	var out bytes.Buffer
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(code))
+	var s scanner.Scanner
+	s.Init(file, code, nil, 0)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return out.Bytes()
+		}
+		if tok != token.STRING {
+			continue
+		}
+		value, err := strconv.Unquote(lit)
+		if err != nil {
+			continue
+		}
+		for _, f := range bytes.Fields([]byte(value)) {
+			// keep the markup intact
+			if bytes.ContainsAny(f, `"'<>`) {
+				continue
+			}
+			out.WriteByte(' ')
+			out.Write(f)
+		}
+	}
+}
+
+type rewriter struct {
+	data []byte
+	pos  int
+	out  bytes.Buffer
+}
+
+// skipLine moves to the start of the next line.
+func (r *rewriter) skipLine() {
+	if i := bytes.IndexByte(r.data[r.pos:], '\n'); i != -1 {
+		r.pos += i + 1
+	} else {
+		r.pos = len(r.data)
+	}
+}
+
+// file rewrites the bodies of the templ components, skipping everything else
+// such as Go code and css components.
+func (r *rewriter) file() {
+	for r.pos < len(r.data) {
+		if !bytes.HasPrefix(r.data[r.pos:], templB) {
+			r.skipLine()
+			continue
+		}
+		// the body starts after the signature, and ends with a closing brace at
+		// the start of a line
+		r.skipLine()
+		end := bytes.Index(r.data[r.pos:], []byte("\n}"))
+		if end == -1 {
+			end = len(r.data) - r.pos
+		}
+		r.body(r.pos + end)
+		r.out.WriteByte('\n')
+	}
+}
+
+// body rewrites the body of a component up to end.
+func (r *rewriter) body(end int) {
+	lineStart := true
+	for r.pos < end {
+		c := r.data[r.pos]
+		if isSpace(c) {
+			if c == '\n' {
+				lineStart = true
+			}
+			r.out.WriteByte(c)
+			r.pos++
+			continue
+		}
+		if lineStart {
+			lineStart = false
+			if r.statement() {
+				continue
+			}
+		}
+		switch {
+		case c == '{':
+			// expressions in text and raw Go code
+			r.pos = goEnd(r.data, r.pos)
+			r.out.WriteByte(' ')
+		case c == '<' && r.pos+1 < len(r.data) && (isLetter(r.data[r.pos+1]) || r.data[r.pos+1] == '/'):
+			r.tag()
+		default:
+			r.out.WriteByte(c)
+			r.pos++
+		}
+	}
+	if r.pos < end {
+		r.pos = end
+	}
+	r.pos++
+}
+
+// statement skips a line of Go code at the current position, returning false
+// if there isn't one.
+func (r *rewriter) statement() bool {
+	rest := r.data[r.pos:]
+	switch {
+	case rest[0] == '@':
+		// component calls, with their arguments possibly over several lines
+		if i := bytes.IndexAny(rest, "(\n"); i != -1 && rest[i] == '(' {
+			r.pos = goEnd(r.data, r.pos+i)
+		}
+		r.skipLine()
+		return true
+	case rest[0] == '}':
+		if !bytes.HasPrefix(rest, []byte("} else")) {
+			r.pos++
+			return true
+		}
+	}
+	for _, s := range statements {
+		if bytes.HasPrefix(rest, s) {
+			r.skipLine()
+			return true
+		}
+	}
+	return false
+}
+
+// tag rewrites the tag at the current position.
+func (r *rewriter) tag() {
+	var quote byte
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			end := goEnd(r.data, r.pos)
+			prev := r.out.Bytes()
+			if len(prev) > 0 && prev[len(prev)-1] == '=' {
+				r.out.WriteByte('"')
+				r.out.Write(literals(r.data[r.pos+1 : end]))
+				r.out.WriteString(` "`)
+			} else {
+				// spread attributes, and the braces of conditional attributes
+				r.out.WriteByte(' ')
+			}
+			r.pos = end
+			continue
+		case c == '}':
+			// the end of conditional attributes
+			r.out.WriteByte(' ')
+			r.pos++
+			continue
+		case c == '?' && r.pos+1 < len(r.data) && r.data[r.pos+1] == '=':
+			// boolean attributes like disabled?={ cond }
+			r.pos++
+			continue
+		case bytes.HasPrefix(r.data[r.pos:], []byte("if ")) && isSpace(r.data[r.pos-1]):
+			// conditional attributes
+			if i := bytes.IndexByte(r.data[r.pos:], '{'); i != -1 {
+				r.pos += i + 1
+				r.out.WriteByte(' ')
+				continue
+			}
+		case c == '>':
+			r.out.WriteByte(c)
+			r.pos++
+			return
+		}
+		r.out.WriteByte(c)
+		r.pos++
+	}
+}
+
+// Extract returns the usage information for a templ file.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rw := rewriter{data: data}
+	rw.file()
+	return htmlusage.ExtractWith(&rw.out, nil)
+}
@@ -0,0 +1,142 @@
+package templusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name  string
+		templ string
+		seen  []cssselector.Selector
+	}{
+		{
+			name: "plain markup",
+			templ: `package views
+
+templ Hello(name string) {
+	<div class="greeting">Hello, { name }!</div>
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "div", Class: set("greeting")},
+			},
+		},
+		{
+			name: "expressions in attributes",
+			templ: `package views
+
+templ Button(primary bool) {
+	<button class={ "btn", templ.KV("btn-primary", primary) } id={ "main" } disabled?={ !primary }>Go</button>
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "button", ID: "main", Class: set("btn", "btn-primary"), Attr: set("disabled")},
+			},
+		},
+		{
+			name: "control flow",
+			templ: `package views
+
+templ List(items []string) {
+	if len(items) == 0 {
+		<p class="empty">None</p>
+	} else {
+		<ul>
+			for _, item := range items {
+				<li class={ "item" }>{ item }</li>
+			}
+		</ul>
+	}
+	switch x {
+		case "a":
+			<b></b>
+		default:
+			<i></i>
+	}
+	@Footer(map[string]string{
+		"<a>": "b",
+	})
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "p", Class: set("empty")},
+				{Tag: "ul"},
+				{Tag: "li", Class: set("item")},
+				{Tag: "b"},
+				{Tag: "i"},
+			},
+		},
+		{
+			name: "conditional attributes",
+			templ: `package views
+
+templ Input(required bool) {
+	<input type="text"
+		if required {
+			class="required"
+		}
+	/>
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "input", Class: set("required"), Attr: set("type")},
+			},
+		},
+		{
+			name: "go code is skipped",
+			templ: `package views
+
+func less(a, b int) bool { return a<b }
+
+css red() {
+	color: red;
+}
+
+templ Page() {
+	<main></main>
+}
+`,
+			seen: []cssselector.Selector{
+				{Tag: "main"},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.templ))
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-templusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/tmplusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = tmplusage.Extract(bytes.NewReader(b), nil)
+	return 0
+}
@@ -0,0 +1,303 @@
+// Package tmplusage extracts usage information from HTML templates using
+// delimited tags, such as Jinja, Django, Liquid, Nunjucks, Twig and Handlebars
+// templates. Tags are masked out so the markup and the literal parts of
+// attribute values in all branches of conditionals are seen by the HTML
+// extractor, and the bodies of blocks and macros are extracted as fragments
+// which may be nested in any document.
+package tmplusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/htmlusage"
+
+	"github.com/pkg/errors"
+)
+
+// Delim is a pair of delimiters enclosing a template tag.
+type Delim struct {
+	Left, Right string
+	// Comment tags are masked out entirely, including their string literals.
+	Comment bool
+}
+
+// DefaultDelims covers the common template engines.
+var DefaultDelims = []Delim{
+	{Left: "{#", Right: "#}", Comment: true},
+	{Left: "{{!--", Right: "--}}", Comment: true},
+	{Left: "{{!", Right: "}}", Comment: true},
+	{Left: "{%", Right: "%}"},
+	{Left: "{{{", Right: "}}}"},
+	{Left: "{{", Right: "}}"},
+}
+
+// fragments are the tags whose bodies may be rendered in other templates.
+var fragments = map[string]bool{
+	"block":  true,
+	"macro":  true,
+	"inline": true,
+}
+
+// ParseDelim parses a pair of delimiters separated by whitespace, like "{% %}".
+func ParseDelim(s string, comment bool) (Delim, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Delim{}, errors.Errorf("invalid template delimiters: %q", s)
+	}
+	return Delim{Left: fields[0], Right: fields[1], Comment: comment}, nil
+}
+
+// open is a tag whose body is being processed.
+type open struct {
+	name string
+	out  *bytes.Buffer
+}
+
+type scanner struct {
+	data   []byte
+	pos    int
+	delims []Delim
+	next   []int // the cached position of the next left delimiter of each pair
+
+	// out is the stack of outputs, with the document at the bottom and the
+	// fragments being processed above it.
+	out       []open
+	fragments []*bytes.Buffer
+	comment   bool // in a comment block
+}
+
+func (s *scanner) current() *bytes.Buffer {
+	return s.out[len(s.out)-1].out
+}
+
+// mask writes spaces for the data, keeping the newlines.
+func (s *scanner) mask(data []byte) {
+	w := s.current()
+	for _, b := range data {
+		if b == '\n' {
+			w.WriteByte(b)
+		} else {
+			w.WriteByte(' ')
+		}
+	}
+}
+
+// literal writes the contents of a string literal, replacing anything which
+// cannot be part of a name with spaces so it cannot change the markup.
+func (s *scanner) literal(data []byte) {
+	w := s.current()
+	for _, b := range data {
+		if isNameByte(b) {
+			w.WriteByte(b)
+		} else {
+			w.WriteByte(' ')
+		}
+	}
+}
+
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b >= 0x80
+}
+
+// tag returns the position and delimiters of the next tag, preferring the
+// longest left delimiter when several start at the same position.
+func (s *scanner) tag() (int, *Delim) {
+	best, bestDelim := -1, (*Delim)(nil)
+	for i := range s.delims {
+		if s.next[i] != -1 && s.next[i] < s.pos {
+			s.next[i] = bytes.Index(s.data[s.pos:], []byte(s.delims[i].Left))
+			if s.next[i] != -1 {
+				s.next[i] += s.pos
+			}
+		}
+		at := s.next[i]
+		if at == -1 {
+			continue
+		}
+		if best == -1 || at < best || at == best && len(s.delims[i].Left) > len(bestDelim.Left) {
+			best, bestDelim = at, &s.delims[i]
+		}
+	}
+	return best, bestDelim
+}
+
+// scan processes all of the data, splitting it into the document and
+// fragments.
+func (s *scanner) scan() {
+	for s.pos < len(s.data) {
+		at, d := s.tag()
+		if at == -1 {
+			s.text(s.data[s.pos:])
+			return
+		}
+		s.text(s.data[s.pos:at])
+		s.pos = at
+		s.action(d)
+	}
+}
+
+// text writes the text between tags, unless it is commented out.
+func (s *scanner) text(data []byte) {
+	if s.comment {
+		s.mask(data)
+	} else {
+		s.current().Write(data)
+	}
+}
+
+// action processes the tag at the current position.
+func (s *scanner) action(d *Delim) {
+	start := s.pos
+	body := start + len(d.Left)
+	if d.Comment {
+		end := bytes.Index(s.data[body:], []byte(d.Right))
+		if end == -1 {
+			s.pos = len(s.data)
+		} else {
+			s.pos = body + end + len(d.Right)
+		}
+		s.mask(s.data[start:s.pos])
+		return
+	}
+
+	end := s.end(d, body)
+	name, closing := keyword(s.data[body:end])
+	switch {
+	case s.comment:
+		if closing && name == "comment" {
+			s.comment = false
+		}
+		s.mask(s.data[start:s.pos])
+		return
+	case name == "comment" && !closing:
+		s.comment = true
+		s.mask(s.data[start:s.pos])
+		return
+	}
+
+	s.mask(s.data[start:body])
+	s.rest(body, end)
+	s.mask(s.data[end:s.pos])
+	switch {
+	case closing && fragments[name]:
+		if len(s.out) > 1 && s.out[len(s.out)-1].name == name {
+			s.out = s.out[:len(s.out)-1]
+		}
+	case !closing && fragments[name]:
+		fragment := new(bytes.Buffer)
+		s.fragments = append(s.fragments, fragment)
+		s.out = append(s.out, open{name: name, out: fragment})
+	}
+}
+
+// end returns the position of the right delimiter of the tag, skipping over
+// string literals, and moves past it.
+func (s *scanner) end(d *Delim, pos int) int {
+	for pos < len(s.data) {
+		switch c := s.data[pos]; {
+		case c == '"' || c == '\'':
+			for pos++; pos < len(s.data) && s.data[pos] != c; pos++ {
+				if s.data[pos] == '\\' {
+					pos++
+				}
+			}
+			pos++
+		case bytes.HasPrefix(s.data[pos:], []byte(d.Right)):
+			s.pos = pos + len(d.Right)
+			return pos
+		default:
+			pos++
+		}
+	}
+	s.pos = len(s.data)
+	return len(s.data)
+}
+
+// rest processes the body of a tag, keeping the contents of string literals.
+func (s *scanner) rest(pos, end int) {
+	for pos < end {
+		c := s.data[pos]
+		if c != '"' && c != '\'' {
+			s.mask(s.data[pos : pos+1])
+			pos++
+			continue
+		}
+		s.mask(s.data[pos : pos+1])
+		pos++
+		start := pos
+		for pos < end && s.data[pos] != c {
+			if s.data[pos] == '\\' && pos+1 < end {
+				pos++
+			}
+			pos++
+		}
+		s.literal(s.data[start:pos])
+		if pos < end {
+			s.mask(s.data[pos : pos+1])
+			pos++
+		}
+	}
+}
+
+// keyword returns the name of the tag, and whether it closes a block like
+// {% endblock %} or {{/inline}}. Whitespace control and Handlebars sigils are
+// ignored.
+func keyword(body []byte) (string, bool) {
+	body = bytes.TrimLeft(body, " \t\r\n-~+")
+	closing := false
+	if len(body) > 0 && body[0] == '/' {
+		closing = true
+	}
+	body = bytes.TrimLeft(body, "#^/*>& \t")
+	end := 0
+	for end < len(body) && body[end] >= 'a' && body[end] <= 'z' {
+		end++
+	}
+	name := string(body[:end])
+	if strings.HasPrefix(name, "end") && len(name) > 3 {
+		return name[3:], true
+	}
+	return name, closing
+}
+
+// Extract returns the usage information for a template using the given
+// delimiters, or DefaultDelims if there are none. The classes, IDs and tags in
+// all branches of conditionals, as well as those in string literals, are
+// considered seen.
+func Extract(r io.Reader, delims []Delim) (*htmlusage.Info, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(delims) == 0 {
+		delims = DefaultDelims
+	}
+	var doc bytes.Buffer
+	s := scanner{
+		data:   data,
+		delims: delims,
+		next:   make([]int, len(delims)),
+		out:    []open{{out: &doc}},
+	}
+	for i := range delims {
+		s.next[i] = bytes.Index(data, []byte(delims[i].Left))
+	}
+	s.scan()
+
+	info, err := htmlusage.ExtractWith(&doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, fragment := range s.fragments {
+		fi, err := htmlusage.ExtractWith(fragment, nil)
+		if err != nil {
+			return nil, err
+		}
+		info.MergeFragments(fi)
+	}
+	return info, nil
+}
@@ -0,0 +1,119 @@
+package tmplusage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+
+	"github.com/daaku/ensure"
+)
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name      string
+		tmpl      string
+		delims    []Delim
+		seen      []cssselector.Selector
+		fragments []cssselector.Selector
+	}{
+		{
+			name: "jinja conditionals in attributes",
+			tmpl: `<a class="btn {% if primary %}btn-primary{% else %}btn-default{% endif %}" {% if x %}hidden{% endif %}>{{ label }}</a>`,
+			seen: []cssselector.Selector{
+				{Tag: "a", Class: set("btn", "btn-primary", "btn-default"), Attr: set("hidden")},
+			},
+		},
+		{
+			name: "string literals in tags",
+			tmpl: `<li class="{{ 'active' if current }} {{ "item" }}" id="{{ id }}">`,
+			seen: []cssselector.Selector{
+				{Tag: "li", Class: set("active", "item")},
+			},
+		},
+		{
+			name: "markup in blocks",
+			tmpl: `{% for item in items %}<li class="item">{{ item }}</li>{% empty %}<p class="empty"></p>{% endfor %}`,
+			seen: []cssselector.Selector{
+				{Tag: "li", Class: set("item")},
+				{Tag: "p", Class: set("empty")},
+			},
+		},
+		{
+			name: "comments",
+			tmpl: `{# <b class="gone"> #}{% comment %}<i class="gone">{{ "gone" }}{% endcomment %}{{!-- <s class="gone"> --}}{{! "gone" }}<div class="kept"></div>`,
+			seen: []cssselector.Selector{
+				{Tag: "div", Class: set("kept")},
+			},
+		},
+		{
+			name: "handlebars",
+			tmpl: `<ul class="{{#if open}}open{{/if}}">{{#each items}}<li>{{{html}}}</li>{{/each}}</ul>`,
+			seen: []cssselector.Selector{
+				{Tag: "ul", Class: set("open")},
+				{Tag: "li"},
+			},
+		},
+		{
+			name: "blocks are fragments",
+			tmpl: `{% extends "base.html" %}{% block content %}<main class="page">{% endblock %}{{#*inline "row"}}<tr>{{/inline}}`,
+			fragments: []cssselector.Selector{
+				{Tag: "main", Class: set("page")},
+				{Tag: "tr"},
+			},
+		},
+		{
+			name:   "custom delimiters",
+			tmpl:   `<p class="a <?= 'b' ?> {{ c }}">`,
+			delims: []Delim{{Left: "<?=", Right: "?>"}},
+			seen: []cssselector.Selector{
+				{Tag: "p", Class: set("a", "b", "{{", "c", "}}")},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.tmpl), c.delims)
+			ensure.Nil(t, err)
+			var seen, fragments []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				seen = append(seen, info.Nodes[id])
+			}
+			for _, id := range info.Fragments {
+				fragments = append(fragments, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, seen, c.seen)
+			ensure.DeepEqual(t, fragments, c.fragments)
+		})
+	}
+}
+
+func TestParseDelim(t *testing.T) {
+	d, err := ParseDelim(" {% %} ", false)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, d, Delim{Left: "{%", Right: "%}"})
+	_, err = ParseDelim("{%", true)
+	ensure.Err(t, err, regexp.MustCompile("invalid template delimiters"))
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-tmplusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f, nil)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
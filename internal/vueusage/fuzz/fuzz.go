@@ -0,0 +1,14 @@
+// +build gofuzz
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/daaku/cssdalek/internal/vueusage"
+)
+
+func Fuzz(b []byte) int {
+	_, _ = vueusage.Extract(bytes.NewReader(b))
+	return 0
+}
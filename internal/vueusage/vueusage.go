@@ -0,0 +1,349 @@
+// Package vueusage extracts usage information from Vue single-file components,
+// and purges their <style> blocks. The <template> block is read as HTML with
+// the class and ID bindings understood, and the string literals in the <script>
+// blocks are words which may be added to any of its nodes, along with the
+// classes matching the patterns inferred from them.
+package vueusage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/daaku/cssdalek/internal/csspurge"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+	"github.com/daaku/cssdalek/internal/jsusage"
+	"github.com/daaku/cssdalek/internal/jsxusage"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	commentStartB = []byte("<!--")
+	commentEndB   = []byte("-->")
+	vBindB        = []byte("v-bind:")
+	vOnB          = []byte("v-on:")
+	onB           = []byte("on")
+	classB        = []byte("class")
+	idB           = []byte("id")
+	styleB        = []byte("style")
+)
+
+// block is a top level block in a component, such as <template>.
+type block struct {
+	name       string
+	attrs      map[string]string
+	start, end int // the range of bytes containing the contents
+}
+
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b == ':'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f'
+}
+
+// hasTagPrefix returns true if the data starts with the tag name, which is
+// compared case insensitively.
+func hasTagPrefix(data []byte, name string) bool {
+	return len(data) > len(name) && bytes.EqualFold(data[:len(name)], []byte(name)) &&
+		!isNameByte(data[len(name)])
+}
+
+// tagEnd returns the index of the '>' ending the tag, skipping over quoted
+// attribute values.
+func tagEnd(doc []byte, pos int) int {
+	var quote byte
+	for ; pos < len(doc); pos++ {
+		switch c := doc[pos]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return pos
+		}
+	}
+	return -1
+}
+
+// parseAttrs returns the attributes with lowercased names.
+func parseAttrs(data []byte) map[string]string {
+	attrs := make(map[string]string)
+	pos := 0
+	for pos < len(data) {
+		for pos < len(data) && (isSpace(data[pos]) || data[pos] == '/') {
+			pos++
+		}
+		start := pos
+		for pos < len(data) && !isSpace(data[pos]) && data[pos] != '=' && data[pos] != '/' {
+			pos++
+		}
+		if start == pos {
+			pos++
+			continue
+		}
+		name := strings.ToLower(string(data[start:pos]))
+		for pos < len(data) && isSpace(data[pos]) {
+			pos++
+		}
+		if pos >= len(data) || data[pos] != '=' {
+			attrs[name] = ""
+			continue
+		}
+		pos++
+		for pos < len(data) && isSpace(data[pos]) {
+			pos++
+		}
+		if pos < len(data) && (data[pos] == '"' || data[pos] == '\'') {
+			quote := data[pos]
+			pos++
+			start = pos
+			for pos < len(data) && data[pos] != quote {
+				pos++
+			}
+			attrs[name] = string(data[start:pos])
+			pos++
+			continue
+		}
+		start = pos
+		for pos < len(data) && !isSpace(data[pos]) {
+			pos++
+		}
+		attrs[name] = string(data[start:pos])
+	}
+	return attrs
+}
+
+// closing returns the end of the contents of the block starting at pos, and
+// the position after its closing tag. Only templates may be nested.
+func closing(doc []byte, name string, pos int) (int, int) {
+	depth := 1
+	for {
+		i := bytes.IndexByte(doc[pos:], '<')
+		if i == -1 {
+			return len(doc), len(doc)
+		}
+		pos += i
+		switch {
+		case pos+1 < len(doc) && doc[pos+1] == '/' && hasTagPrefix(doc[pos+2:], name):
+			depth--
+			if depth == 0 {
+				end := tagEnd(doc, pos)
+				if end == -1 {
+					return pos, len(doc)
+				}
+				return pos, end + 1
+			}
+		case name == "template" && hasTagPrefix(doc[pos+1:], name):
+			depth++
+		}
+		pos++
+	}
+}
+
+// blocks returns the top level blocks in the component.
+func blocks(doc []byte) []block {
+	var bs []block
+	pos := 0
+	for {
+		i := bytes.IndexByte(doc[pos:], '<')
+		if i == -1 {
+			return bs
+		}
+		pos += i
+		if bytes.HasPrefix(doc[pos:], commentStartB) {
+			end := bytes.Index(doc[pos:], commentEndB)
+			if end == -1 {
+				return bs
+			}
+			pos += end + len(commentEndB)
+			continue
+		}
+		nameEnd := pos + 1
+		for nameEnd < len(doc) && isNameByte(doc[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == pos+1 {
+			pos++
+			continue
+		}
+		end := tagEnd(doc, nameEnd)
+		if end == -1 {
+			return bs
+		}
+		b := block{
+			name:  strings.ToLower(string(doc[pos+1 : nameEnd])),
+			attrs: parseAttrs(doc[nameEnd:end]),
+			start: end + 1,
+		}
+		if doc[end-1] == '/' {
+			b.end = b.start
+			pos = b.start
+		} else {
+			b.end, pos = closing(doc, b.name, b.start)
+		}
+		bs = append(bs, b)
+	}
+}
+
+// classes returns the classes in a binding expression, separated by spaces.
+func classes(expr []byte) []byte {
+	set := jsxusage.ClassExpr(expr, nil)
+	names := make([]string, 0, len(set))
+	for c := range set {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return []byte(strings.Join(names, " "))
+}
+
+// attr reads the class and ID bindings as the classes and IDs they may have,
+// other bindings as plain attributes, and event handlers as on* attributes.
+func attr(name, value []byte) ([]byte, []byte) {
+	var bound []byte
+	switch {
+	case bytes.HasPrefix(name, vOnB):
+		return append(append([]byte(nil), onB...), name[len(vOnB):]...), value
+	case len(name) > 1 && name[0] == '@':
+		return append(append([]byte(nil), onB...), name[1:]...), value
+	case bytes.HasPrefix(name, vBindB):
+		bound = name[len(vBindB):]
+	case len(name) > 1 && name[0] == ':':
+		bound = name[1:]
+	default:
+		return name, value
+	}
+	if i := bytes.IndexByte(bound, '.'); i != -1 {
+		bound = bound[:i]
+	}
+	switch {
+	case bytes.EqualFold(bound, classB), bytes.EqualFold(bound, idB):
+		return bound, classes(value)
+	case bytes.EqualFold(bound, styleB):
+		// an object rather than declarations
+		return name, value
+	}
+	return bound, value
+}
+
+// extract returns the usage information for the component, and its blocks.
+func extract(doc []byte) (*htmlusage.Info, []block, error) {
+	bs := blocks(doc)
+	var info *htmlusage.Info
+	for _, b := range bs {
+		if b.name != "template" || (b.attrs["lang"] != "" && b.attrs["lang"] != "html") {
+			continue
+		}
+		var err error
+		info, err = htmlusage.ExtractComponent(bytes.NewReader(doc[b.start:b.end]), attr)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "in <template> at offset %d", b.start)
+		}
+		break
+	}
+	if info == nil {
+		info = new(htmlusage.Info)
+		info.Add(nil)
+	}
+	for _, b := range bs {
+		if b.name != "script" {
+			continue
+		}
+		words, err := jsusage.Extract(bytes.NewReader(doc[b.start:b.end]), nil, false)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "in <script> at offset %d", b.start)
+		}
+		info.AddWords(0, words.Seen)
+		info.AddPatterns(0, words.Patterns)
+	}
+	return info, bs, nil
+}
+
+// Extract returns the usage information for a component.
+func Extract(r io.Reader) (*htmlusage.Info, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	info, _, err := extract(doc)
+	return info, err
+}
+
+// isCSS returns true if the style block contains CSS rather than another
+// language like SCSS.
+func isCSS(b *block) bool {
+	lang := b.attrs["lang"]
+	return b.name == "style" && (lang == "" || lang == "css")
+}
+
+// Purge copies the component from r to w, purging the contents of each
+// <style> block. Scoped styles only apply to the component, so they are
+// purged using its own usage along with u. Other styles apply to the whole
+// page, so the global usage is also used for them. Styles in other languages
+// are copied unchanged. Unless it is nil, excluded wraps the usage once the
+// component's own usage is added, so exclusions apply to all of it. Unless it
+// is nil, keep holds the font faces, keyframes and rules kept regardless of
+// usage.
+func Purge(u, global usage.Info, excluded func(usage.Info) usage.Info, keep *cssusage.Info, l *log.Logger, r io.Reader, w io.Writer) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, bs, err := extract(doc)
+	if err != nil {
+		return err
+	}
+
+	// font-face and keyframes may be used from any of the <style> blocks
+	var cssInfo cssusage.Info
+	if keep != nil {
+		cssInfo.Merge(keep)
+	}
+	cssInfo.Merge(&info.Style)
+	for i := range bs {
+		if !isCSS(&bs[i]) {
+			continue
+		}
+		s, err := cssusage.Extract(bytes.NewReader(doc[bs[i].start:bs[i].end]))
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", bs[i].start)
+		}
+		cssInfo.Merge(s)
+	}
+
+	var scoped, unscoped usage.Info = usage.MultiInfo{u, info}, usage.MultiInfo{u, info, global}
+	if excluded != nil {
+		scoped, unscoped = excluded(scoped), excluded(unscoped)
+	}
+	last := 0
+	for i := range bs {
+		b := &bs[i]
+		if !isCSS(b) {
+			continue
+		}
+		if _, err := w.Write(doc[last:b.start]); err != nil {
+			return errors.WithStack(err)
+		}
+		var bu usage.Info = unscoped
+		if _, found := b.attrs["scoped"]; found {
+			bu = scoped
+		}
+		err := csspurge.Purge(bu, &cssInfo, l, bytes.NewReader(doc[b.start:b.end]), w)
+		if err != nil {
+			return errors.WithMessagef(err, "in <style> at offset %d", b.start)
+		}
+		last = b.end
+	}
+	_, err = w.Write(doc[last:])
+	return errors.WithStack(err)
+}
@@ -0,0 +1,191 @@
+package vueusage
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daaku/cssdalek/internal/cssselector"
+	"github.com/daaku/cssdalek/internal/cssusage"
+	"github.com/daaku/cssdalek/internal/excludeusage"
+	"github.com/daaku/cssdalek/internal/htmlusage"
+	"github.com/daaku/cssdalek/internal/usage"
+
+	"github.com/daaku/ensure"
+)
+
+func seen(t testing.TB, selectors ...string) []cssselector.Selector {
+	var parsed []cssselector.Selector
+	for _, s := range selectors {
+		p, err := cssselector.Parse(strings.NewReader(s))
+		ensure.Nil(t, err, "for selector", s)
+		parsed = append(parsed, p...)
+	}
+	return parsed
+}
+
+func set(values ...string) map[string]struct{} {
+	s := make(map[string]struct{})
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		vue  string
+		seen []cssselector.Selector
+	}{
+		{
+			name: "plain template",
+			vue:  `<template><div class="a"><span>x</span></div></template>`,
+			seen: seen(t, "div.a", "span"),
+		},
+		{
+			name: "object syntax",
+			vue:  `<template><p class="x" :class="{ active: isActive, 'text-danger': hasError }"></p></template>`,
+			seen: seen(t, "p.x.active.text-danger"),
+		},
+		{
+			name: "array syntax",
+			vue:  `<template><p v-bind:class="[isActive ? 'on' : 'off', { big: large }]"></p></template>`,
+			seen: seen(t, "p.on.off.big"),
+		},
+		{
+			name: "id binding",
+			vue:  `<template><p :id="open ? 'opened' : 'closed'"></p></template>`,
+			seen: seen(t, "p#closed", "p#opened"),
+		},
+		{
+			name: "other bindings",
+			vue:  `<template><input :disabled="busy" :style="{ color: c }" @click="go"></template>`,
+			seen: []cssselector.Selector{
+				{Tag: "input", Attr: set("disabled", ":style", "onclick")},
+			},
+		},
+		{
+			name: "nested templates and components",
+			vue: `<!-- <template><b></b></template> -->
+<template>
+  <template v-if="x"><MyButton class="btn" /><UI.Icon /></template>
+  <i></i>
+</template>
+<style>b{}</style>`,
+			seen: []cssselector.Selector{
+				{Tag: "template", Attr: set("v-if")},
+				{Class: set("btn")},
+				{},
+				{Tag: "i"},
+			},
+		},
+		{
+			name: "other template language",
+			vue:  `<template lang="pug">div.a</template>`,
+			seen: nil,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Extract(strings.NewReader(c.vue))
+			ensure.Nil(t, err)
+			var nodes []cssselector.Selector
+			for _, id := range info.Docs[0] {
+				nodes = append(nodes, info.Nodes[id])
+			}
+			ensure.DeepEqual(t, nodes, c.seen)
+		})
+	}
+}
+
+func TestExtractScript(t *testing.T) {
+	info, err := Extract(strings.NewReader(`
+<template><nav class="menu"></nav></template>
+<script setup lang="ts">
+const open: boolean = a / b / c // 'no'
+const re = /'no'/
+el.classList.add('is-open', ` + "`theme-${t} dark`" + `)
+</script>`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Words, []map[string]struct{}{set("is-open", "theme-", "dark")})
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "nav.menu.is-open"))))
+	ensure.True(t, info.Includes(cssselector.Chain(seen(t, "nav.theme-light"))))
+}
+
+func TestPurge(t *testing.T) {
+	vue := `<template><p class="used"></p></template>
+<style scoped>
+.used{color:red}.global{color:red}.unused{color:red}
+</style>
+<style>
+.used{color:red}.global{color:red}.unused{color:red}
+</style>
+<style lang="scss">
+.unused{ .x { color: red } }
+</style>
+`
+	global, err := htmlusage.FromSelectors([]string{".global"})
+	ensure.Nil(t, err)
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, global, nil, nil, l, strings.NewReader(vue), &out))
+	ensure.DeepEqual(t, out.String(), `<template><p class="used"></p></template>
+<style scoped>.used{color:red;}</style>
+<style>.used{color:red;}.global{color:red;}</style>
+<style lang="scss">
+.unused{ .x { color: red } }
+</style>
+`)
+}
+
+// excludeLegacy excludes the classes starting with legacy.
+func excludeLegacy(u usage.Info) usage.Info {
+	return &excludeusage.Info{
+		Exclude: &excludeusage.ExcludeClass{Re: []*regexp.Regexp{regexp.MustCompile(`^legacy`)}},
+		Keep:    usage.MultiInfo{},
+		Usage:   u,
+	}
+}
+
+func TestPurgeExcluded(t *testing.T) {
+	vue := `<template><p class="used legacy-box"></p></template>
+<style scoped>.used{color:red}.legacy-box{color:red}</style>
+<style>.used{color:red}.legacy-box{color:red}</style>
+`
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, usage.MultiInfo{}, excludeLegacy, nil, l, strings.NewReader(vue), &out))
+	ensure.DeepEqual(t, out.String(), `<template><p class="used legacy-box"></p></template>
+<style scoped>.used{color:red;}</style>
+<style>.used{color:red;}</style>
+`)
+}
+
+func TestPurgeKeep(t *testing.T) {
+	vue := `<template><p></p></template>
+<style scoped>@keyframes spin{to{x:y}}@keyframes fade{to{x:y}}</style>
+`
+	keep := cssusage.Info{KeepKeyframesRe: []*regexp.Regexp{regexp.MustCompile(`^spin`)}}
+	var out bytes.Buffer
+	l := log.New(ioutil.Discard, "", 0)
+	ensure.Nil(t, Purge(usage.MultiInfo{}, usage.MultiInfo{}, nil, &keep, l, strings.NewReader(vue), &out))
+	ensure.DeepEqual(t, out.String(), `<template><p></p></template>
+<style scoped>@keyframes spin{to{x:y;}}</style>
+`)
+}
+
+func TestReaderError(t *testing.T) {
+	f, err := ioutil.TempFile("", "cssdalek-vueusage-")
+	ensure.Nil(t, err)
+	f.Close()
+	os.Remove(f.Name())
+	_, err = Extract(f)
+	ensure.True(t, errors.Is(err, os.ErrClosed))
+}
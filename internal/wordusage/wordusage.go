@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"sort"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -14,6 +15,24 @@ import (
 
 type Info struct {
 	Seen map[string]struct{}
+	// Patterns match the classes built dynamically, like 'btn-' + variant.
+	Patterns map[Pattern]struct{}
+}
+
+// Pattern matches the names with the prefix and the suffix, along with
+// something in between.
+type Pattern struct {
+	Prefix, Suffix string
+}
+
+// Matches returns true if the name matches the pattern.
+func (p Pattern) Matches(name string) bool {
+	return len(name) > len(p.Prefix)+len(p.Suffix) &&
+		strings.HasPrefix(name, p.Prefix) && strings.HasSuffix(name, p.Suffix)
+}
+
+func (p Pattern) String() string {
+	return p.Prefix + "*" + p.Suffix
 }
 
 func (i *Info) Merge(other *Info) {
@@ -23,6 +42,24 @@ func (i *Info) Merge(other *Info) {
 	for k := range other.Seen {
 		i.Seen[k] = struct{}{}
 	}
+	if len(other.Patterns) > 0 && i.Patterns == nil {
+		i.Patterns = make(map[Pattern]struct{})
+	}
+	for p := range other.Patterns {
+		i.Patterns[p] = struct{}{}
+	}
+}
+
+// SortedPatterns returns the patterns in order.
+func (i *Info) SortedPatterns() []Pattern {
+	patterns := make([]Pattern, 0, len(i.Patterns))
+	for p := range i.Patterns {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(a, b int) bool {
+		return patterns[a].String() < patterns[b].String()
+	})
+	return patterns
 }
 
 func (i *Info) contains(k string) bool {
@@ -42,9 +79,26 @@ func (i *Info) containsAll(m map[string]struct{}) bool {
 	return true
 }
 
+func (i *Info) containsClasses(m map[string]struct{}) bool {
+outer:
+	for k := range m {
+		if i.contains(k) {
+			continue
+		}
+		k = strings.ToLower(k)
+		for p := range i.Patterns {
+			if p.Matches(k) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
 func (i *Info) Includes(chain cssselector.Chain) bool {
 	for _, s := range chain {
-		contains := i.contains(s.ID) && i.contains(s.Tag) && i.containsAll(s.Class) && i.containsAll(s.Attr)
+		contains := i.contains(s.ID) && i.contains(s.Tag) && i.containsClasses(s.Class) && i.containsAll(s.Attr)
 		if !contains {
 			return false
 		}
@@ -52,25 +106,86 @@ func (i *Info) Includes(chain cssselector.Chain) bool {
 	return true
 }
 
-func isWordRune(r rune) bool {
-	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-'
+// DocInfo holds the words seen in each document separately. A chain is only
+// included if all of its parts are found within the same document.
+type DocInfo struct {
+	Docs []*Info
+}
+
+func (i *DocInfo) Merge(other *Info) {
+	i.Docs = append(i.Docs, other)
 }
 
-func scanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+func (i *DocInfo) Includes(chain cssselector.Chain) bool {
+	for _, doc := range i.Docs {
+		if doc.Includes(chain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokenizer splits text into words. Letters and numbers are always part of
+// words.
+type Tokenizer struct {
+	// Runes are the other runes which are part of words.
+	Runes string
+	// Separators are the runes within words at which they are also split into
+	// pieces. Both the whole word and its pieces are seen.
+	Separators string
+	// Brackets allows anything but whitespace within square brackets in words,
+	// as in arbitrary values like bg-[#fff].
+	Brackets bool
+}
+
+var (
+	// DefaultTokenizer keeps names like foo-bar whole.
+	DefaultTokenizer = &Tokenizer{Runes: "-"}
+	// BEMTokenizer keeps names like card__title--large whole, and also sees
+	// the blocks, elements and modifiers in them.
+	BEMTokenizer = &Tokenizer{Runes: "-_", Separators: "-_"}
+	// TailwindTokenizer keeps names like md:hover:bg-[#fff] and w-1/2 whole,
+	// and also sees the variants and fractions in them.
+	TailwindTokenizer = &Tokenizer{Runes: "-_:/.!@", Separators: ":/", Brackets: true}
+)
+
+// Tokenizers holds the presets by name.
+var Tokenizers = map[string]*Tokenizer{
+	"default":  DefaultTokenizer,
+	"bem":      BEMTokenizer,
+	"tailwind": TailwindTokenizer,
+}
+
+func (t *Tokenizer) isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || strings.ContainsRune(t.Runes, r)
+}
+
+func (t *Tokenizer) isSeparator(r rune) bool {
+	return strings.ContainsRune(t.Separators, r)
+}
+
+func (t *Tokenizer) scanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	// Skip leading non word runes.
 	start := 0
 	for width := 0; start < len(data); start += width {
 		var r rune
 		r, width = utf8.DecodeRune(data[start:])
-		if isWordRune(r) {
+		if t.isWordRune(r) || (t.Brackets && r == '[') {
 			break
 		}
 	}
 	// Scan until we have word runes.
+	depth := 0
 	for width, i := 0, start; i < len(data); i += width {
 		var r rune
 		r, width = utf8.DecodeRune(data[i:])
-		if !isWordRune(r) {
+		switch {
+		case t.Brackets && r == '[':
+			depth++
+		case t.Brackets && r == ']' && depth > 0:
+			depth--
+		case depth > 0 && !unicode.IsSpace(r):
+		case !t.isWordRune(r):
 			return i + width, data[start:i], nil
 		}
 	}
@@ -82,15 +197,31 @@ func scanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	return start, nil, nil
 }
 
-func Extract(r io.Reader) (*Info, error) {
+// Extract returns the words in the text.
+func (t *Tokenizer) Extract(r io.Reader) (*Info, error) {
 	i := &Info{Seen: make(map[string]struct{})}
 	scanner := bufio.NewScanner(r)
-	scanner.Split(scanWords)
+	scanner.Split(t.scanWords)
 	for scanner.Scan() {
-		i.Seen[string(bytes.ToLower(scanner.Bytes()))] = struct{}{}
+		// words may end sentences or labels
+		word := bytes.ToLower(bytes.Trim(scanner.Bytes(), ".:"))
+		if len(word) == 0 {
+			continue
+		}
+		i.Seen[string(word)] = struct{}{}
+		if pieces := bytes.FieldsFunc(word, t.isSeparator); len(pieces) > 1 {
+			for _, p := range pieces {
+				i.Seen[string(p)] = struct{}{}
+			}
+		}
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, errors.WithStack(err)
 	}
 	return i, nil
 }
+
+// Extract returns the words in the text using the DefaultTokenizer.
+func Extract(r io.Reader) (*Info, error) {
+	return DefaultTokenizer.Extract(r)
+}
@@ -104,6 +104,43 @@ func TestDoesNotInclude(t *testing.T) {
 	}
 }
 
+func TestPatternIncludes(t *testing.T) {
+	i := Info{
+		Seen: set("div"),
+		Patterns: map[Pattern]struct{}{
+			{Prefix: "btn-"}:                  {},
+			{Prefix: "text-", Suffix: "-500"}: {},
+		},
+	}
+	for selector, included := range map[string]bool{
+		"div.btn-primary":   true,
+		".btn-":             false,
+		".text-red-500":     true,
+		".text-red-400":     false,
+		".btn-a.text-b-500": true,
+		"#btn-a":            false,
+		"span.btn-a":        false,
+	} {
+		chain, err := cssselector.Parse(strings.NewReader(selector))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, i.Includes(chain), included, selector)
+	}
+}
+
+func TestDocInfoIncludes(t *testing.T) {
+	var i DocInfo
+	i.Merge(&Info{Seen: set("foo")})
+	i.Merge(&Info{Seen: set("bar", "baz")})
+
+	chain, err := cssselector.Parse(strings.NewReader(".bar .baz"))
+	ensure.Nil(t, err)
+	ensure.True(t, i.Includes(chain))
+
+	chain, err = cssselector.Parse(strings.NewReader(".foo .bar"))
+	ensure.Nil(t, err)
+	ensure.False(t, i.Includes(chain))
+}
+
 func TestExtract(t *testing.T) {
 	cases := []struct {
 		name string
@@ -146,6 +183,50 @@ func TestExtract(t *testing.T) {
 	}
 }
 
+func TestTokenizers(t *testing.T) {
+	cases := []struct {
+		name      string
+		tokenizer *Tokenizer
+		in        string
+		seen      map[string]struct{}
+	}{
+		{
+			name:      "default splits at underscores",
+			tokenizer: DefaultTokenizer,
+			in:        `card__title md:flex`,
+			seen:      set("card", "title", "md", "flex"),
+		},
+		{
+			name:      "bem",
+			tokenizer: BEMTokenizer,
+			in:        `<h2 class="card__title card--large_variant">`,
+			seen: set("h2", "class", "card__title", "card--large_variant",
+				"card", "title", "large", "variant"),
+		},
+		{
+			name:      "tailwind",
+			tokenizer: TailwindTokenizer,
+			in:        `<div class="md:flex w-1/2 bg-[#fff] !mt-0 [&>p]:m-2">Done.</div>`,
+			seen: set("div", "class", "md:flex", "md", "flex", "w-1/2", "w-1", "2",
+				"bg-[#fff]", "!mt-0", "[&>p]:m-2", "[&>p]", "m-2", "done", "/div"),
+		},
+		{
+			name:      "custom",
+			tokenizer: &Tokenizer{Runes: "-_$"},
+			in:        `$var_name foo-bar`,
+			seen:      set("$var_name", "foo-bar"),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			info, err := c.tokenizer.Extract(strings.NewReader(c.in))
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, info.Seen, c.seen)
+		})
+	}
+}
+
 func TestReaderError(t *testing.T) {
 	f, err := ioutil.TempFile("", "cssdalek-wordusage-")
 	ensure.Nil(t, err)